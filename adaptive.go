@@ -0,0 +1,98 @@
+package gospot
+
+import (
+	"fmt"
+	"math"
+)
+
+// SetAdaptive enables or disables the online tracking of the excess
+// threshold. When enabled, the level quantile of the data is tracked with
+// a streaming [P2] estimator and the excess threshold follows it every
+// time the tail is fitted. The stored peaks are then re-based on the new
+// threshold (the ones that fall below it are dropped) and the ratio of
+// excesses is set to 1-level, as the threshold is the level quantile by
+// construction. The tracker is trained by [Spot.Fit].
+func (spot *Spot) SetAdaptive(enabled bool) {
+	if !enabled {
+		spot.Tracker = nil
+		return
+	}
+	if spot.Tracker == nil {
+		spot.Tracker = NewP2()
+		spot.Tracker.Init(spot.trackedLevel())
+	}
+}
+
+// trackedLevel returns the probability of the tracked quantile
+func (spot *Spot) trackedLevel() float64 {
+	if spot.Low {
+		return 1.0 - spot.Level
+	}
+	return spot.Level
+}
+
+// trainTracker feeds the tracker with the training data from scratch and
+// returns its estimate
+func (spot *Spot) trainTracker(data []float64) float64 {
+	spot.Tracker.Init(spot.trackedLevel())
+	return spot.Tracker.quantile(data)
+}
+
+// adapt moves the excess threshold to the value of the tracker
+func (spot *Spot) adapt() {
+	spot.adaptTo(spot.trackedThreshold())
+}
+
+// trackedThreshold returns the excess threshold given by the tracker (NaN
+// if the detector is not adaptive)
+func (spot *Spot) trackedThreshold() float64 {
+	if spot.Tracker == nil {
+		return math.NaN()
+	}
+	return spot.Tracker.Value()
+}
+
+// adaptShift returns how much the peaks are shifted when the excess
+// threshold moves to et (0 if it does not move)
+func (spot *Spot) adaptShift(et float64) float64 {
+	if math.IsNaN(et) || math.IsNaN(spot.ExcessThreshold) || et == spot.ExcessThreshold {
+		return 0.0
+	}
+	return spot.upDown() * (et - spot.ExcessThreshold)
+}
+
+// adaptTo moves the excess threshold to et
+func (spot *Spot) adaptTo(et float64) {
+	delta := spot.adaptShift(et)
+	if delta == 0.0 {
+		return
+	}
+	spot.Tail.Peaks.shift(delta)
+	spot.ExcessThreshold = et
+
+	nt := uint64(math.Round((1.0 - spot.Level) * float64(spot.N)))
+	if spot.Nt > 0 {
+		spot.Clusters = uint64(float64(spot.Clusters) * float64(nt) / float64(spot.Nt))
+	}
+	spot.Nt = nt
+	if spot.ClusterSize > 0 {
+		spot.ClusterMax -= delta
+		if spot.ClusterMax <= 0 {
+			spot.ClusterSize = 0
+			spot.ClusterMax = 0.0
+		}
+		spot.ClusterSize = min(spot.ClusterSize, spot.Nt)
+	}
+}
+
+// checkTracker checks the consistency of a decoded tracker
+func (spot *Spot) checkTracker() error {
+	p2 := spot.Tracker
+	if p2 == nil {
+		return nil
+	}
+	if len(p2.Q) != 5 || len(p2.N) != 5 || len(p2.NP) != 5 || len(p2.DN) != 5 {
+		return fmt.Errorf("spot: the tracker must have 5 markers")
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package gospot
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestAdaptiveShift(t *testing.T) {
+	run := func(adaptive bool, steps uint64) *Spot {
+		s, _ := NewSpot(1e-4, false, true, 0.98, 1000)
+		s.SetAdaptive(adaptive)
+		if err := s.Fit(gaussian(20_000)); err != nil {
+			t.Fatal(err)
+		}
+		// the body of the distribution moves up
+		for _, x := range gaussian(steps) {
+			s.Step(x + 3)
+		}
+		return s
+	}
+
+	fixed := run(false, 5_000)
+	if ratio := float64(fixed.Nt) / float64(fixed.N); ratio < 0.1 {
+		t.Fatalf("a fixed threshold must be flooded with excesses: %v", ratio)
+	}
+
+	s := run(true, 200_000)
+	if ratio := float64(s.Nt) / float64(s.N); math.Abs(ratio-0.02) > 0.005 {
+		t.Errorf("bad excess ratio: %v", ratio)
+	}
+	// 0.98 quantile of the mixture
+	if s.ExcessThreshold < 4.5 || s.ExcessThreshold > 5.5 {
+		t.Errorf("the excess threshold must follow the data: %v", s.ExcessThreshold)
+	}
+	if s.Tail.Peaks.Min <= 0 {
+		t.Errorf("the peaks must be positive: %v", s.Tail.Peaks.Min)
+	}
+	if math.Abs(s.Quantile(0.02)-s.ExcessThreshold) > 0.1 {
+		t.Errorf("the quantile must be consistent with the threshold")
+	}
+	anomalies := 0
+	for _, x := range gaussian(100_000) {
+		if s.Step(x+3) == ANOMALY {
+			anomalies++
+		}
+	}
+	if anomalies > 100 {
+		t.Errorf("too many anomalies after the shift: %d", anomalies)
+	}
+}
+
+func TestAdaptiveLowTail(t *testing.T) {
+	s := defaultSpot().LowerTail()
+	s.SetAdaptive(true)
+	if err := s.Fit(gaussian(20_000)); err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range gaussian(100_000) {
+		s.Step(x - 3)
+	}
+	if s.ExcessThreshold > -3.5 || s.AnomalyThreshold >= s.ExcessThreshold {
+		t.Errorf("the lower threshold must follow the data: %v, %v", s.ExcessThreshold, s.AnomalyThreshold)
+	}
+}
+
+func TestSetAdaptive(t *testing.T) {
+	s := defaultSpot()
+	s.SetAdaptive(true)
+	tracker := s.Tracker
+	s.SetAdaptive(true)
+	if tracker == nil || s.Tracker != tracker {
+		t.Errorf("the tracker must be kept")
+	}
+	s.Fit(gaussian(10_000))
+	if s.Tracker.Count != 10_000 || s.Tracker.Value() != s.ExcessThreshold {
+		t.Errorf("the tracker must be trained by the fit")
+	}
+
+	// discarded anomalies are not tracked
+	s.Step(s.AnomalyThreshold + 1)
+	if s.Tracker.Count != 10_000 {
+		t.Errorf("anomalies must not be tracked")
+	}
+	s.Step(0)
+	if s.Tracker.Count != 10_001 {
+		t.Errorf("values must be tracked")
+	}
+
+	s.Reset()
+	if s.Tracker.Count != 0 {
+		t.Errorf("the tracker must be reset")
+	}
+	s.SetAdaptive(false)
+	if s.Tracker != nil {
+		t.Errorf("the tracker must be removed")
+	}
+
+	c := SpotConfig{Q: 1e-4, Level: 0.98, MaxExcess: 100, Adaptive: true}
+	if s, err := c.New(); err != nil || s.Tracker == nil {
+		t.Errorf("adaptive mode not set from the configuration")
+	}
+}
+
+func TestAdaptiveState(t *testing.T) {
+	s := defaultSpot()
+	s.SetAdaptive(true)
+	s.Fit(gaussian(20_000))
+	for _, x := range gaussian(1_000) {
+		s.Step(x + 1)
+	}
+
+	raw, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBinary := &Spot{}
+	if err := fromBinary.UnmarshalBinary(raw); err != nil {
+		t.Fatal(err)
+	}
+	raw, err = json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON := &Spot{}
+	if err := json.Unmarshal(raw, fromJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	data := gaussian(5_000)
+	for _, x := range data {
+		s.Step(x + 1)
+	}
+	for _, other := range []*Spot{fromBinary, fromJSON} {
+		if other.Tracker == nil {
+			t.Fatalf("tracker not restored")
+		}
+		for _, x := range data {
+			other.Step(x + 1)
+		}
+		if other.Tracker.Value() != s.Tracker.Value() || other.ExcessThreshold != s.ExcessThreshold {
+			t.Errorf("restored tracker does not behave like the original")
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`{"tail":{"peaks":{"container":{"capacity":0,"data":[]}}},"tracker":{"q":[1]}}`), &Spot{}); err == nil {
+		t.Errorf("must return an error on a bad tracker")
+	}
+	s.Tracker.Q = nil
+	if _, err := s.MarshalBinary(); err == nil {
+		t.Errorf("must return an error on a bad tracker")
+	}
+}
@@ -0,0 +1,148 @@
+package gospot
+
+import (
+	"sync"
+)
+
+// AsyncSpot is a [SyncSpot] whose tail is fitted in the background.
+// [AsyncSpot.Step] only pushes the excesses into the peaks and returns. A
+// background goroutine fits a copy of the tail and swaps the new model in.
+// Excesses that arrive during a fit are coalesced into the next one.
+//
+// [AsyncSpot.Close] must be called to stop the background goroutine.
+type AsyncSpot struct {
+	*SyncSpot
+	// signals new fit requests, completed fits and closing
+	cond *sync.Cond
+	// number of requested fits
+	requested uint64
+	// number of requested fits that have been handled
+	completed uint64
+	// epoch of the detector when the last fit has been requested
+	requestEpoch uint64
+	closed       bool
+	done         chan struct{}
+}
+
+// NewAsyncSpot wraps the given detector and starts the background fitting
+// goroutine. The detector must not be used directly afterwards.
+func NewAsyncSpot(spot *Spot) *AsyncSpot {
+	s := NewSyncSpot(spot)
+	a := &AsyncSpot{
+		SyncSpot: s,
+		cond:     sync.NewCond(&s.mu),
+		done:     make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Step updates the detector with a fresh value x (see [Spot.Step]). When x
+// is an excess, the tail is fitted in the background. Once the instance is
+// closed, the tail is fitted inline.
+func (a *AsyncSpot) Step(x float64) SpotStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.step(x)
+}
+
+// StepDetailed updates the detector with a fresh value x like
+// [AsyncSpot.Step] (see [Spot.StepDetailed])
+func (a *AsyncSpot) StepDetailed(x float64) StepResult {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	result := a.spot.detail(x)
+	result.Status = a.step(x)
+	return result
+}
+
+// step must be called with the lock held
+func (a *AsyncSpot) step(x float64) SpotStatus {
+	if a.closed {
+		status := a.spot.Step(x)
+		a.publish()
+		return status
+	}
+
+	status, changed := a.spot.push(x)
+	if changed && a.spot.refitDue() {
+		a.requested++
+		a.requestEpoch = a.epoch
+		a.cond.Broadcast()
+	}
+	return status
+}
+
+// run fits the tail until the instance is closed
+func (a *AsyncSpot) run() {
+	defer close(a.done)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for {
+		for a.completed == a.requested && !a.closed {
+			a.cond.Wait()
+		}
+		if a.completed == a.requested {
+			// closed and nothing left to do
+			return
+		}
+
+		target := a.requested
+		epoch := a.epoch
+		if epoch != a.requestEpoch {
+			// the requests are obsolete, the detector has been modified
+			// (fitted, reset...) since then
+			a.completed = target
+			a.cond.Broadcast()
+			continue
+		}
+		// the excess threshold moves along with the new model so that the
+		// readers never see a mix of both
+		et := a.spot.trackedThreshold()
+		tail := a.spot.Tail.Clone()
+		if delta := a.spot.adaptShift(et); delta != 0.0 {
+			tail.Peaks.shift(delta)
+		}
+
+		a.mu.Unlock()
+		tail.Fit()
+		a.mu.Lock()
+
+		// the model is dropped if the detector has been modified in the meantime
+		if a.epoch == epoch {
+			a.spot.adaptTo(et)
+			a.spot.Tail.Gamma = tail.Gamma
+			a.spot.Tail.Sigma = tail.Sigma
+			a.spot.Tail.Winner = tail.Winner
+			a.spot.updateThresholds()
+			if a.spot.Refit != nil {
+				a.spot.Refit.fitted(a.spot.Tail.Peaks)
+			}
+			a.publish()
+		}
+		a.completed = target
+		a.cond.Broadcast()
+	}
+}
+
+// Flush waits until the fits requested so far are done
+func (a *AsyncSpot) Flush() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	target := a.requested
+	for a.completed < target {
+		a.cond.Wait()
+	}
+}
+
+// Close waits for the pending fits and stops the background goroutine. It
+// can be called several times.
+func (a *AsyncSpot) Close() {
+	a.mu.Lock()
+	a.closed = true
+	a.cond.Broadcast()
+	a.mu.Unlock()
+	<-a.done
+}
@@ -0,0 +1,193 @@
+package gospot
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestAsyncSpotFlush(t *testing.T) {
+	s, err := NewSpot(1e-4, false, false, 0.98, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := NewSpot(1e-4, false, false, 0.98, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAsyncSpot(s)
+	defer a.Close()
+
+	training := gaussian(50_000)
+	if err := a.Fit(training); err != nil {
+		t.Fatal(err)
+	}
+	if err := ref.Fit(training); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, x := range gaussian(20_000) {
+		if a.Step(x) != ref.Step(x) {
+			t.Fatalf("anomalies are not discarded, statuses must be the same")
+		}
+	}
+	// the winner must come from the background fit
+	a.Flush()
+	a.View(func(spot *Spot) { spot.Tail.Winner = "stale" })
+
+	// the last value is an excess
+	a.Step(ref.ExcessThreshold + 0.1)
+	ref.Step(ref.ExcessThreshold + 0.1)
+
+	a.Flush()
+	m := a.Model()
+	if m.Gamma != ref.Tail.Gamma || m.Sigma != ref.Tail.Sigma || m.AnomalyThreshold != ref.AnomalyThreshold {
+		t.Errorf("background fit differs from the inline one: %+v != %v, %v, %v", m, ref.Tail.Gamma, ref.Tail.Sigma, ref.AnomalyThreshold)
+	}
+	a.View(func(spot *Spot) {
+		if spot.N != ref.N || spot.Nt != ref.Nt {
+			t.Errorf("bad counters")
+		}
+		if spot.Tail.Winner != ref.Tail.Winner {
+			t.Errorf("bad winner: %q != %q", spot.Tail.Winner, ref.Tail.Winner)
+		}
+	})
+}
+
+func TestAsyncSpotClose(t *testing.T) {
+	a := NewAsyncSpot(defaultSpot())
+	if err := a.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range gaussian(10_000) {
+		a.Step(x)
+	}
+	a.Close()
+	a.Close()
+
+	// pending fits are done on close, next ones are inline
+	m := a.Model()
+	a.Step(m.ExcessThreshold + 0.1)
+	if a.Model() == m {
+		t.Errorf("tail must be fitted inline once closed")
+	}
+	a.Flush()
+}
+
+func TestAsyncSpotReset(t *testing.T) {
+	a := NewAsyncSpot(defaultSpot())
+	defer a.Close()
+	if err := a.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range gaussian(10_000) {
+		a.Step(x)
+	}
+	a.Reset()
+	a.Flush()
+
+	a.View(func(spot *Spot) {
+		if spot.Tail.Gamma != 0.0 || spot.Tail.Sigma != 0.0 {
+			t.Errorf("a pending fit has been applied after reset")
+		}
+	})
+}
+
+func TestAsyncSpotRace(t *testing.T) {
+	a := NewAsyncSpot(defaultSpot())
+	defer a.Close()
+	if err := a.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, x := range gaussian(2_000) {
+				a.Step(x)
+				a.Model()
+				a.Quantile(1e-3)
+			}
+			a.Flush()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestAsyncSpotStepDetailed(t *testing.T) {
+	a := NewAsyncSpot(defaultSpot())
+	defer a.Close()
+	if err := a.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+	m := a.Model()
+	x := m.AnomalyThreshold + 1
+	p := a.Probability(x)
+	r := a.StepDetailed(x)
+	if r.Status != ANOMALY || r.Probability != p || r.AnomalyThreshold != m.AnomalyThreshold {
+		t.Errorf("bad result: %+v", r)
+	}
+
+	a.Close()
+	if r := a.StepDetailed(m.ExcessThreshold + 0.1); r.Status != EXCESS {
+		t.Errorf("bad result after close: %+v", r)
+	}
+}
+
+func TestAsyncSpotAdaptive(t *testing.T) {
+	armed := false
+	started, release := make(chan struct{}), make(chan struct{})
+	estimator := hookEstimator(t, func() {
+		if armed {
+			armed = false
+			close(started)
+			<-release
+		}
+	})
+
+	s := defaultSpot()
+	s.SetAdaptive(true)
+	s.Tail.SetEstimators([]string{estimator}, SelectMaxLikelihood)
+	if err := s.Fit(gaussian(20_000)); err != nil {
+		t.Fatal(err)
+	}
+	a := NewAsyncSpot(s)
+	defer a.Close()
+	// the body of the distribution moves up so that the tracker moves
+	for _, x := range gaussian(5_000) {
+		a.Step(x + 1)
+	}
+	a.Flush()
+
+	// normal values move the tracker without fitting the tail
+	m := a.Model()
+	for _, x := range gaussian(1_000) {
+		a.Step(math.Min(x+1, m.ExcessThreshold-0.01))
+	}
+	if a.Model() != m {
+		t.Fatalf("the tail must not be fitted on normal values")
+	}
+	a.View(func(spot *Spot) { armed = true })
+	a.Step(m.ExcessThreshold + 0.1)
+	<-started
+
+	// the excess threshold is not moved before the new model is ready
+	a.View(func(spot *Spot) {
+		if spot.ExcessThreshold != m.ExcessThreshold || spot.Tail.Gamma != m.Gamma {
+			t.Errorf("the model is mixed during the background fit: %v (%v before)", spot.ExcessThreshold, m.ExcessThreshold)
+		}
+		if math.IsNaN(spot.trackedThreshold()) || spot.trackedThreshold() == m.ExcessThreshold {
+			t.Errorf("the tracker must have moved")
+		}
+	})
+	close(release)
+	a.Flush()
+
+	a.View(func(spot *Spot) {
+		if spot.ExcessThreshold == m.ExcessThreshold || spot.Tail.Peaks.Min <= 0 {
+			t.Errorf("the excess threshold must follow the tracker along with the model")
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package gospot
+
+import (
+	"fmt"
+	"math"
+)
+
+// Baseline models the local behavior of a drifting stream. [DSpot] removes
+// it from the incoming data before looking at the tails.
+type Baseline interface {
+	// Push adds a new value to the baseline
+	Push(x float64)
+	// Value returns the current baseline
+	Value() float64
+	// Ready tells whether enough data have been pushed to compute the baseline
+	Ready() bool
+	// Reset puts the baseline into its initial state
+	Reset()
+}
+
+// MovingAverage is a [Baseline] that computes the mean of the last values
+type MovingAverage struct {
+	// Sum of the stored values
+	Sum float64 `json:"sum"`
+	// Last values
+	Window *Ubend `json:"window"`
+}
+
+// NewMovingAverage initializes a moving average over the last depth values
+// (depth must be positive)
+func NewMovingAverage(depth uint64) (*MovingAverage, error) {
+	if depth == 0 {
+		return nil, fmt.Errorf("depth must be positive")
+	}
+	return &MovingAverage{
+		Sum:    0.0,
+		Window: NewUbend(depth),
+	}, nil
+}
+
+// Push a new value to the window
+func (m *MovingAverage) Push(x float64) {
+	erased := m.Window.Push(x)
+	m.Sum += x
+	if !math.IsNaN(erased) {
+		m.Sum -= erased
+	}
+}
+
+// Value returns the mean of the window
+func (m *MovingAverage) Value() float64 {
+	return m.Sum / float64(m.Window.Size())
+}
+
+// Ready returns true once the window is filled
+func (m *MovingAverage) Ready() bool {
+	return m.Window.Filled
+}
+
+// Reset empties the window
+func (m *MovingAverage) Reset() {
+	m.Sum = 0.0
+	m.Window = NewUbend(m.Window.Capacity)
+}
+
+// EWMA is a [Baseline] that computes an exponentially weighted moving average
+type EWMA struct {
+	// Smoothing factor (weight of the new value)
+	Alpha float64 `json:"alpha"`
+	// Number of values to see before being ready
+	Warmup uint64 `json:"warmup"`
+	// Number of seen values
+	Count uint64 `json:"count"`
+	// Current average
+	Mean float64 `json:"mean"`
+}
+
+// NewEWMA initializes an exponentially weighted moving average. The
+// smoothing factor alpha must be in (0, 1].
+func NewEWMA(alpha float64, warmup uint64) (*EWMA, error) {
+	if !(alpha > 0.0 && alpha <= 1.0) {
+		return nil, fmt.Errorf("alpha must be in (0, 1]")
+	}
+	return &EWMA{
+		Alpha:  alpha,
+		Warmup: warmup,
+		Count:  0,
+		Mean:   0.0,
+	}, nil
+}
+
+// Push a new value to the average
+func (e *EWMA) Push(x float64) {
+	if e.Count == 0 {
+		e.Mean = x
+	} else {
+		e.Mean += e.Alpha * (x - e.Mean)
+	}
+	e.Count++
+}
+
+// Value returns the current average
+func (e *EWMA) Value() float64 {
+	if e.Count == 0 {
+		return math.NaN()
+	}
+	return e.Mean
+}
+
+// Ready returns true once the warmup is over
+func (e *EWMA) Ready() bool {
+	return e.Count > 0 && e.Count >= e.Warmup
+}
+
+// Reset forgets the past values
+func (e *EWMA) Reset() {
+	e.Count = 0
+	e.Mean = 0.0
+}
@@ -0,0 +1,85 @@
+package gospot
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMovingAverage(t *testing.T) {
+	var depth uint64 = 5
+	m, err := NewMovingAverage(depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := uint64(1); i <= depth; i++ {
+		if m.Ready() {
+			t.Errorf("must not be ready after %d values", i-1)
+		}
+		m.Push(float64(i))
+	}
+	if !m.Ready() {
+		t.Errorf("must be ready")
+	}
+	if m.Value() != 3.0 {
+		t.Errorf("bad average: %v != %v", m.Value(), 3.0)
+	}
+
+	m.Push(11.0)
+	if m.Value() != 5.0 {
+		t.Errorf("bad average: %v != %v", m.Value(), 5.0)
+	}
+
+	m.Reset()
+	if m.Ready() || m.Window.Size() != 0 || m.Sum != 0.0 {
+		t.Errorf("moving average not reset")
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	e, err := NewEWMA(0.5, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Ready() || !math.IsNaN(e.Value()) {
+		t.Errorf("must not be ready")
+	}
+
+	e.Push(2.0)
+	if e.Ready() {
+		t.Errorf("must not be ready during warmup")
+	}
+	if e.Value() != 2.0 {
+		t.Errorf("bad average: %v != %v", e.Value(), 2.0)
+	}
+
+	e.Push(4.0)
+	if !e.Ready() {
+		t.Errorf("must be ready")
+	}
+	if e.Value() != 3.0 {
+		t.Errorf("bad average: %v != %v", e.Value(), 3.0)
+	}
+
+	e.Reset()
+	if e.Ready() || e.Count != 0 {
+		t.Errorf("ewma not reset")
+	}
+}
+
+func TestBadBaseline(t *testing.T) {
+	if _, err := NewMovingAverage(0); err == nil {
+		t.Errorf("must return an error because depth = 0")
+	}
+	for _, alpha := range []float64{0.0, -0.5, 1.5, math.NaN()} {
+		if _, err := NewEWMA(alpha, 10); err == nil {
+			t.Errorf("must return an error because alpha = %v", alpha)
+		}
+	}
+	if _, err := NewEWMA(1.0, 10); err != nil {
+		t.Errorf("alpha = 1 is valid: %v", err)
+	}
+	if _, err := NewDSpot(1e-4, false, true, 0.98, 300, 0); err == nil {
+		t.Errorf("must return an error because depth = 0")
+	}
+}
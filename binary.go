@@ -0,0 +1,456 @@
+package gospot
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"time"
+)
+
+// Binary snapshot layout (little-endian):
+//
+//	header:  magic (4 bytes) | version (uint16) | reserved (uint16) | crc32 of the payload (uint32)
+//	payload: parameters | counters | thresholds | tail | peaks | ubend (chronological order) | sections
+//
+// Optional state (like the refit policy) is stored in trailing sections
+// made of a tag (uint8), a length (uint32) and the content. A new
+// version of the format is only required when the layout of the payload
+// changes, old versions remain readable.
+const (
+	snapshotMagic      = "GSPT"
+	snapshotVersion    = uint16(1)
+	snapshotHeaderSize = 12
+	// largest ring accepted when decoding (512 MiB of peaks), so that a
+	// forged capacity cannot exhaust the memory
+	snapshotMaxCapacity = uint64(1) << 26
+)
+
+// snapshot section tags
+const (
+	sectionRefit uint8 = iota + 1
+	sectionTiers
+	sectionDecluster
+	sectionTracker
+	sectionEstimators
+	sectionConfidence
+	sectionCursor
+)
+
+var (
+	// ErrSnapshotMagic is returned when the data is not a snapshot
+	ErrSnapshotMagic = errors.New("gospot: bad snapshot magic number")
+	// ErrSnapshotChecksum is returned when the payload does not match the checksum
+	ErrSnapshotChecksum = errors.New("gospot: snapshot checksum mismatch")
+	// ErrSnapshotTruncated is returned when the snapshot is shorter than expected
+	ErrSnapshotTruncated = errors.New("gospot: truncated snapshot")
+	// ErrSnapshotCorrupted is returned when the snapshot content is not consistent
+	ErrSnapshotCorrupted = errors.New("gospot: corrupted snapshot")
+)
+
+// SnapshotVersionError is returned when the snapshot has been written with
+// an unknown version of the format
+type SnapshotVersionError struct {
+	Version uint16
+}
+
+func (e *SnapshotVersionError) Error() string {
+	return fmt.Sprintf("gospot: unsupported snapshot version %d (latest is %d)", e.Version, snapshotVersion)
+}
+
+// snapshotWriter appends little-endian values to a buffer
+type snapshotWriter struct {
+	buf []byte
+}
+
+func (w *snapshotWriter) u8(x uint8) {
+	w.buf = append(w.buf, x)
+}
+
+func (w *snapshotWriter) bool(x bool) {
+	if x {
+		w.u8(1)
+	} else {
+		w.u8(0)
+	}
+}
+
+func (w *snapshotWriter) u64(x uint64) {
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, x)
+}
+
+func (w *snapshotWriter) f64(x float64) {
+	w.u64(math.Float64bits(x))
+}
+
+func (w *snapshotWriter) str(s string) {
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// section writes a tagged section whose content is produced by f
+func (w *snapshotWriter) section(tag uint8, f func(w *snapshotWriter)) {
+	w.u8(tag)
+	start := len(w.buf)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, 0)
+	f(w)
+	binary.LittleEndian.PutUint32(w.buf[start:], uint32(len(w.buf)-start-4))
+}
+
+// snapshotReader reads little-endian values. The first error is kept and
+// the next reads return zero values.
+type snapshotReader struct {
+	buf []byte
+	err error
+}
+
+func (r *snapshotReader) next(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if len(r.buf) < n {
+		r.err = ErrSnapshotTruncated
+		return make([]byte, n)
+	}
+	out := r.buf[:n]
+	r.buf = r.buf[n:]
+	return out
+}
+
+func (r *snapshotReader) u8() uint8 {
+	return r.next(1)[0]
+}
+
+func (r *snapshotReader) bool() bool {
+	return r.u8() != 0
+}
+
+func (r *snapshotReader) u32() uint32 {
+	return binary.LittleEndian.Uint32(r.next(4))
+}
+
+func (r *snapshotReader) u64() uint64 {
+	return binary.LittleEndian.Uint64(r.next(8))
+}
+
+func (r *snapshotReader) f64() float64 {
+	return math.Float64frombits(r.u64())
+}
+
+func (r *snapshotReader) str() string {
+	n := r.u32()
+	if r.err == nil && uint64(n) > uint64(len(r.buf)) {
+		r.err = ErrSnapshotTruncated
+	}
+	return string(r.next(int(n)))
+}
+
+// MarshalBinary encodes the Spot instance into a compact snapshot
+// (it implements [encoding.BinaryMarshaler])
+func (spot *Spot) MarshalBinary() ([]byte, error) {
+	if err := spot.checkTracker(); err != nil {
+		return nil, err
+	}
+	peaks := spot.Tail.Peaks
+	data := peaks.Container.Chronological()
+
+	w := &snapshotWriter{buf: make([]byte, snapshotHeaderSize, snapshotHeaderSize+128+8*len(data))}
+	w.f64(spot.Q)
+	w.f64(spot.Level)
+	w.bool(spot.Low)
+	w.bool(spot.DiscardAnomalies)
+	w.u64(spot.Nt)
+	w.u64(spot.N)
+	w.f64(spot.AnomalyThreshold)
+	w.f64(spot.ExcessThreshold)
+	w.f64(spot.Tail.Gamma)
+	w.f64(spot.Tail.Sigma)
+	w.f64(peaks.E)
+	w.f64(peaks.E2)
+	w.u64(peaks.Container.Capacity)
+	w.f64(peaks.Container.LastErasedData)
+	w.u64(uint64(len(data)))
+	for _, x := range data {
+		w.f64(x)
+	}
+
+	// the position of the oldest value so that the ring can be restored as is
+	if c := peaks.Container; c.Filled && c.Cursor != 0 {
+		w.section(sectionCursor, func(w *snapshotWriter) {
+			w.u64(c.Cursor)
+		})
+	}
+
+	if spot.Refit != nil {
+		w.section(sectionRefit, func(w *snapshotWriter) {
+			p := spot.Refit
+			w.u64(p.Every)
+			w.f64(p.Tolerance)
+			w.u64(uint64(p.Interval))
+			w.u64(p.Pending)
+			w.f64(p.LastMean)
+			w.f64(p.LastVar)
+			t, _ := p.LastFit.MarshalBinary()
+			w.buf = append(w.buf, t...)
+		})
+	}
+
+	if len(spot.Tiers) > 0 || spot.DiscardTier != 0 {
+		w.section(sectionTiers, func(w *snapshotWriter) {
+			w.u64(uint64(spot.DiscardTier))
+			w.u64(uint64(len(spot.Tiers)))
+			for i, q := range spot.Tiers {
+				threshold := math.NaN()
+				if i < len(spot.TierThresholds) {
+					threshold = spot.TierThresholds[i]
+				}
+				w.f64(q)
+				w.f64(threshold)
+			}
+		})
+	}
+
+	if spot.Decluster > 0 || spot.Clusters > 0 {
+		w.section(sectionDecluster, func(w *snapshotWriter) {
+			w.u64(spot.Decluster)
+			w.u64(spot.Clusters)
+			w.u64(spot.ClusterSize)
+			w.f64(spot.ClusterMax)
+			w.u64(spot.ClusterEnd)
+		})
+	}
+
+	if tail := spot.Tail; len(tail.Estimators) > 0 || tail.Selection != "" || tail.Winner != "" {
+		w.section(sectionEstimators, func(w *snapshotWriter) {
+			w.str(string(tail.Selection))
+			w.str(tail.Winner)
+			w.u64(uint64(len(tail.Estimators)))
+			for _, name := range tail.Estimators {
+				w.str(name)
+			}
+		})
+	}
+
+	if spot.Confidence != nil {
+		w.section(sectionConfidence, func(w *snapshotWriter) {
+			p := spot.Confidence
+			w.f64(p.Level)
+			w.str(string(p.Method))
+			w.u64(uint64(p.Samples))
+			w.bool(spot.ThresholdInterval != nil)
+			if spot.ThresholdInterval != nil {
+				w.f64(spot.ThresholdInterval.Lower)
+				w.f64(spot.ThresholdInterval.Upper)
+				w.f64(spot.ThresholdInterval.Level)
+			}
+		})
+	}
+
+	if spot.Tracker != nil {
+		w.section(sectionTracker, func(w *snapshotWriter) {
+			p2 := spot.Tracker
+			w.u64(p2.Count)
+			for _, markers := range [][]float64{p2.Q, p2.N, p2.NP, p2.DN} {
+				for i := 0; i < 5; i++ {
+					w.f64(markers[i])
+				}
+			}
+		})
+	}
+
+	copy(w.buf, snapshotMagic)
+	binary.LittleEndian.PutUint16(w.buf[4:], snapshotVersion)
+	binary.LittleEndian.PutUint16(w.buf[6:], 0)
+	binary.LittleEndian.PutUint32(w.buf[8:], crc32.ChecksumIEEE(w.buf[snapshotHeaderSize:]))
+	return w.buf, nil
+}
+
+// UnmarshalBinary decodes a snapshot produced by [Spot.MarshalBinary]
+// (it implements [encoding.BinaryUnmarshaler]). The returned errors wrap
+// [ErrSnapshotMagic], [ErrSnapshotChecksum], [ErrSnapshotTruncated] or
+// [ErrSnapshotCorrupted], or are a [*SnapshotVersionError].
+func (spot *Spot) UnmarshalBinary(data []byte) error {
+	if len(data) < snapshotHeaderSize {
+		return ErrSnapshotTruncated
+	}
+	if string(data[:4]) != snapshotMagic {
+		return ErrSnapshotMagic
+	}
+	version := binary.LittleEndian.Uint16(data[4:])
+	if version == 0 || version > snapshotVersion {
+		return &SnapshotVersionError{Version: version}
+	}
+	payload := data[snapshotHeaderSize:]
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(data[8:]) {
+		return ErrSnapshotChecksum
+	}
+
+	// only one version exists so far, the next ones will dispatch here
+	out, err := decodeSnapshotV1(&snapshotReader{buf: payload})
+	if err != nil {
+		return err
+	}
+	*spot = *out
+	return nil
+}
+
+func decodeSnapshotV1(r *snapshotReader) (*Spot, error) {
+	spot := &Spot{}
+	spot.Q = r.f64()
+	spot.Level = r.f64()
+	spot.Low = r.bool()
+	spot.DiscardAnomalies = r.bool()
+	spot.Nt = r.u64()
+	spot.N = r.u64()
+	spot.AnomalyThreshold = r.f64()
+	spot.ExcessThreshold = r.f64()
+	gamma := r.f64()
+	sigma := r.f64()
+	e := r.f64()
+	e2 := r.f64()
+	capacity := r.u64()
+	lastErasedData := r.f64()
+	size := r.u64()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if size > capacity || spot.Nt > spot.N {
+		return nil, fmt.Errorf("%w: inconsistent counters", ErrSnapshotCorrupted)
+	}
+	if capacity > snapshotMaxCapacity {
+		return nil, fmt.Errorf("%w: capacity %d is too large", ErrSnapshotCorrupted, capacity)
+	}
+	if size > uint64(len(r.buf))/8 {
+		return nil, ErrSnapshotTruncated
+	}
+
+	spot.Tail = NewTail(capacity)
+	spot.Tail.Gamma = gamma
+	spot.Tail.Sigma = sigma
+	container := spot.Tail.Peaks.Container
+	for i := uint64(0); i < size; i++ {
+		container.Data[i] = r.f64()
+	}
+	if capacity > 0 {
+		container.Cursor = size % capacity
+	}
+	container.Filled = size == capacity
+	container.LastErasedData = lastErasedData
+	if err := spot.Tail.Peaks.restore(e, e2); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupted, err)
+	}
+
+	for len(r.buf) > 0 {
+		tag := r.u8()
+		length := r.u32()
+		content := &snapshotReader{buf: r.next(int(length))}
+		if r.err != nil {
+			return nil, r.err
+		}
+		switch tag {
+		case sectionRefit:
+			p := &RefitPolicy{}
+			p.Every = content.u64()
+			p.Tolerance = content.f64()
+			p.Interval = time.Duration(content.u64())
+			p.Pending = content.u64()
+			p.LastMean = content.f64()
+			p.LastVar = content.f64()
+			if content.err != nil {
+				return nil, fmt.Errorf("%w: refit section", ErrSnapshotCorrupted)
+			}
+			if err := p.LastFit.UnmarshalBinary(content.buf); err != nil {
+				return nil, fmt.Errorf("%w: refit section: %v", ErrSnapshotCorrupted, err)
+			}
+			spot.Refit = p
+		case sectionTiers:
+			spot.DiscardTier = int(content.u64())
+			n := content.u64()
+			if content.err != nil || uint64(len(content.buf)) != 16*n {
+				return nil, fmt.Errorf("%w: tiers section", ErrSnapshotCorrupted)
+			}
+			spot.Tiers = make([]float64, n)
+			spot.TierThresholds = make([]float64, n)
+			for i := range spot.Tiers {
+				spot.Tiers[i] = content.f64()
+				spot.TierThresholds[i] = content.f64()
+			}
+			if err := spot.checkTiers(); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupted, err)
+			}
+		case sectionDecluster:
+			spot.Decluster = content.u64()
+			spot.Clusters = content.u64()
+			spot.ClusterSize = content.u64()
+			spot.ClusterMax = content.f64()
+			spot.ClusterEnd = content.u64()
+			if content.err != nil || len(content.buf) > 0 {
+				return nil, fmt.Errorf("%w: decluster section", ErrSnapshotCorrupted)
+			}
+			if err := spot.checkClusters(); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupted, err)
+			}
+		case sectionTracker:
+			p2 := NewP2()
+			p2.Count = content.u64()
+			for _, markers := range [][]float64{p2.Q, p2.N, p2.NP, p2.DN} {
+				for i := range markers {
+					markers[i] = content.f64()
+				}
+			}
+			if content.err != nil || len(content.buf) > 0 {
+				return nil, fmt.Errorf("%w: tracker section", ErrSnapshotCorrupted)
+			}
+			spot.Tracker = p2
+		case sectionEstimators:
+			tail := spot.Tail
+			tail.Selection = Selection(content.str())
+			tail.Winner = content.str()
+			n := content.u64()
+			if content.err != nil || n > uint64(len(content.buf))/4 {
+				return nil, fmt.Errorf("%w: estimators section", ErrSnapshotCorrupted)
+			}
+			tail.Estimators = make([]string, n)
+			for i := range tail.Estimators {
+				tail.Estimators[i] = content.str()
+			}
+			if content.err != nil || len(content.buf) > 0 {
+				return nil, fmt.Errorf("%w: estimators section", ErrSnapshotCorrupted)
+			}
+			if err := checkEstimators(tail.Estimators, tail.Selection); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupted, err)
+			}
+		case sectionCursor:
+			cursor := content.u64()
+			if content.err != nil || len(content.buf) > 0 || !container.Filled || cursor >= capacity {
+				return nil, fmt.Errorf("%w: cursor section", ErrSnapshotCorrupted)
+			}
+			rotated := make([]float64, capacity)
+			for i, x := range container.Data {
+				rotated[(cursor+uint64(i))%capacity] = x
+			}
+			container.Data = rotated
+			container.Cursor = cursor
+		case sectionConfidence:
+			p := &ConfidencePolicy{}
+			p.Level = content.f64()
+			p.Method = IntervalMethod(content.str())
+			p.Samples = int(content.u64())
+			if content.bool() {
+				spot.ThresholdInterval = &Interval{Lower: content.f64(), Upper: content.f64(), Level: content.f64()}
+			}
+			if content.err != nil || len(content.buf) > 0 {
+				return nil, fmt.Errorf("%w: confidence section", ErrSnapshotCorrupted)
+			}
+			if err := p.check(); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupted, err)
+			}
+			spot.Confidence = p
+		default:
+			return nil, fmt.Errorf("%w: unknown section %d", ErrSnapshotCorrupted, tag)
+		}
+	}
+
+	return spot, nil
+}
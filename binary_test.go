@@ -0,0 +1,222 @@
+package gospot
+
+import (
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"math"
+	"testing"
+	"time"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = &Spot{}
+	_ encoding.BinaryUnmarshaler = &Spot{}
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	s := defaultSpot()
+	if err := s.Fit(gaussian(20_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{0, 100_000} {
+		for _, x := range gaussian(uint64(n)) {
+			s.Step(x)
+		}
+
+		raw, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		other := &Spot{}
+		if err := other.UnmarshalBinary(raw); err != nil {
+			t.Fatal(err)
+		}
+		if other.AnomalyThreshold != s.AnomalyThreshold || other.Tail.Gamma != s.Tail.Gamma || other.Tail.Sigma != s.Tail.Sigma {
+			t.Errorf("model not restored")
+		}
+		if other.Tail.Peaks.Min != s.Tail.Peaks.Min || other.Tail.Peaks.Max != s.Tail.Peaks.Max {
+			t.Errorf("peaks stats not restored")
+		}
+
+		if other.Tail.Peaks.Container.Cursor != s.Tail.Peaks.Container.Cursor {
+			t.Errorf("ring cursor not restored")
+		}
+		for i, x := range s.Tail.Peaks.Container.Data {
+			if other.Tail.Peaks.Container.Data[i] != x {
+				t.Fatalf("ring not restored at %d", i)
+			}
+		}
+
+		// the ring is restored as is so the fits are identical
+		for _, x := range gaussian(10_000) {
+			s.Step(x)
+			other.Step(x)
+		}
+		if s.AnomalyThreshold != other.AnomalyThreshold {
+			t.Errorf("restored instance does not behave like the original: %v != %v", other.AnomalyThreshold, s.AnomalyThreshold)
+		}
+	}
+}
+
+func TestBinaryNotFitted(t *testing.T) {
+	s := defaultSpot()
+	raw, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &Spot{}
+	if err := other.UnmarshalBinary(raw); err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(other.AnomalyThreshold) || other.Tail.Peaks.Container.Capacity != 1000 {
+		t.Errorf("bad restored instance")
+	}
+	if err := other.Fit(gaussian(50_000)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBinaryRefit(t *testing.T) {
+	s := defaultSpot()
+	s.Refit = &RefitPolicy{Every: 3, Tolerance: 0.1, Interval: time.Hour}
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+	s.Step(s.ExcessThreshold + 0.1)
+
+	raw, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &Spot{}
+	if err := other.UnmarshalBinary(raw); err != nil {
+		t.Fatal(err)
+	}
+	if other.Refit == nil || !other.Refit.LastFit.Equal(s.Refit.LastFit) {
+		t.Fatalf("refit policy not restored")
+	}
+	other.Refit.LastFit = s.Refit.LastFit
+	if *other.Refit != *s.Refit {
+		t.Errorf("refit policy not restored: %+v != %+v", other.Refit, s.Refit)
+	}
+}
+
+func TestBinarySize(t *testing.T) {
+	s, err := NewSpot(1e-5, false, true, 0.99, 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Fit(gaussian(500_000)); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) > 8*2000+200 || len(raw) >= len(js) {
+		t.Errorf("snapshot is too large: %d bytes (json: %d bytes)", len(raw), len(js))
+	}
+}
+
+func TestBinaryErrors(t *testing.T) {
+	s := defaultSpot()
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := func(f func(b []byte) []byte) error {
+		b := make([]byte, len(raw))
+		copy(b, raw)
+		return (&Spot{}).UnmarshalBinary(f(b))
+	}
+
+	err = corrupt(func(b []byte) []byte { b[0] = 'X'; return b })
+	if !errors.Is(err, ErrSnapshotMagic) {
+		t.Errorf("bad error: %v", err)
+	}
+
+	err = corrupt(func(b []byte) []byte { binary.LittleEndian.PutUint16(b[4:], 42); return b })
+	var verr *SnapshotVersionError
+	if !errors.As(err, &verr) || verr.Version != 42 {
+		t.Errorf("bad error: %v", err)
+	}
+
+	err = corrupt(func(b []byte) []byte { b[len(b)-1] ^= 0xff; return b })
+	if !errors.Is(err, ErrSnapshotChecksum) {
+		t.Errorf("bad error: %v", err)
+	}
+
+	err = corrupt(func(b []byte) []byte { return b[:8] })
+	if !errors.Is(err, ErrSnapshotTruncated) {
+		t.Errorf("bad error: %v", err)
+	}
+
+	// a truncated payload with a valid checksum
+	err = corrupt(func(b []byte) []byte {
+		b = b[:len(b)-8]
+		binary.LittleEndian.PutUint32(b[8:], crc32.ChecksumIEEE(b[snapshotHeaderSize:]))
+		return b
+	})
+	if !errors.Is(err, ErrSnapshotTruncated) {
+		t.Errorf("bad error: %v", err)
+	}
+
+	// a huge capacity must not be allocated
+	err = corrupt(func(b []byte) []byte {
+		// the capacity follows the parameters, counters, thresholds and tail
+		binary.LittleEndian.PutUint64(b[snapshotHeaderSize+8+8+1+1+8+8+8+8+8+8+8+8:], 1<<62)
+		binary.LittleEndian.PutUint32(b[8:], crc32.ChecksumIEEE(b[snapshotHeaderSize:]))
+		return b
+	})
+	if !errors.Is(err, ErrSnapshotCorrupted) {
+		t.Errorf("bad error: %v", err)
+	}
+
+	// an unknown estimator
+	err = corrupt(func(b []byte) []byte {
+		b = append(b, sectionEstimators)
+		b = binary.LittleEndian.AppendUint32(b, 4+4+8+4+7)
+		b = binary.LittleEndian.AppendUint32(b, 0)
+		b = binary.LittleEndian.AppendUint32(b, 0)
+		b = binary.LittleEndian.AppendUint64(b, 1)
+		b = binary.LittleEndian.AppendUint32(b, 7)
+		b = append(b, "unknown"...)
+		binary.LittleEndian.PutUint32(b[8:], crc32.ChecksumIEEE(b[snapshotHeaderSize:]))
+		return b
+	})
+	if !errors.Is(err, ErrSnapshotCorrupted) {
+		t.Errorf("bad error: %v", err)
+	}
+
+	// a cursor out of the ring
+	err = corrupt(func(b []byte) []byte {
+		b = append(b, sectionCursor, 8, 0, 0, 0)
+		b = binary.LittleEndian.AppendUint64(b, s.Tail.Peaks.Container.Capacity)
+		binary.LittleEndian.PutUint32(b[8:], crc32.ChecksumIEEE(b[snapshotHeaderSize:]))
+		return b
+	})
+	if !errors.Is(err, ErrSnapshotCorrupted) {
+		t.Errorf("bad error: %v", err)
+	}
+
+	// an unknown section with a valid checksum
+	err = corrupt(func(b []byte) []byte {
+		b = append(b, 0xff, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(b[8:], crc32.ChecksumIEEE(b[snapshotHeaderSize:]))
+		return b
+	})
+	if !errors.Is(err, ErrSnapshotCorrupted) {
+		t.Errorf("bad error: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package gospot
+
+import (
+	"fmt"
+	"math"
+)
+
+// BiSpot runs the SPOT algorithm on both tails of the distribution at
+// once. It embeds two [Spot] instances (one per tail) that are trained
+// and updated together.
+type BiSpot struct {
+	// Upper tail detector
+	Upper *Spot `json:"upper"`
+	// Lower tail detector
+	Lower *Spot `json:"lower"`
+}
+
+// NewBiSpot initializes and returns a new BiSpot instance.
+//
+// Parameters:
+//   - qLow: Decision probability of the lower tail
+//   - qHigh: Decision probability of the upper tail
+//   - discardAnomalies: Do not include anomalies in the models (generally true)
+//   - level: Excess level (it is a high quantile that delimits both tails)
+//   - maxExcess: Maximum number of data that are kept to analyze each tail
+//
+// The constraints of [NewSpot] apply to both tails.
+func NewBiSpot(qLow, qHigh float64, discardAnomalies bool, level float64, maxExcess uint64) (*BiSpot, error) {
+	lower, err := NewSpot(qLow, true, discardAnomalies, level, maxExcess)
+	if err != nil {
+		return nil, fmt.Errorf("lower tail: %w", err)
+	}
+	upper, err := NewSpot(qHigh, false, discardAnomalies, level, maxExcess)
+	if err != nil {
+		return nil, fmt.Errorf("upper tail: %w", err)
+	}
+	return &BiSpot{Upper: upper, Lower: lower}, nil
+}
+
+// Reset puts both tails into their initial state (before fitting)
+func (b *BiSpot) Reset() {
+	b.Upper.Reset()
+	b.Lower.Reset()
+}
+
+// Fit both tails against the given values. The data are read once
+// to feed the two tails.
+func (b *BiSpot) Fit(data []float64) error {
+	for _, s := range []*Spot{b.Upper, b.Lower} {
+		s.Nt = 0
+		s.N = uint64(len(data))
+	}
+
+	high := P2Quantile(b.Upper.Level, data)
+	low := P2Quantile(1.0-b.Lower.Level, data)
+	if math.IsNaN(high) || math.IsNaN(low) {
+		return fmt.Errorf("excess threshold is NaN")
+	}
+	b.Upper.ExcessThreshold = high
+	b.Lower.ExcessThreshold = low
+
+	for _, s := range []*Spot{b.Upper, b.Lower} {
+		s.resetClusters()
+		if s.Tracker != nil {
+			s.trainTracker(data)
+		}
+	}
+	for i, x := range data {
+		n := uint64(i + 1)
+		if x > high {
+			b.Upper.Nt++
+			b.Upper.addExcess(x-high, n)
+		} else if x < low {
+			b.Lower.Nt++
+			b.Lower.addExcess(low-x, n)
+		}
+	}
+
+	for _, s := range []*Spot{b.Upper, b.Lower} {
+		s.flushCluster()
+		s.refit()
+		s.updateBootstrap()
+		if math.IsNaN(s.AnomalyThreshold) {
+			return fmt.Errorf("anomaly threshold is NaN")
+		}
+	}
+	return nil
+}
+
+// Step updates the BiSpot instance with a fresh value x
+// It returns:
+//   - [ANOMALY_HIGH]/[ANOMALY_LOW]: the data is beyond the anomaly threshold of the upper/lower tail
+//   - [EXCESS_HIGH]/[EXCESS_LOW]: the data is in the upper/lower tail and has triggered a model update
+//   - [NORMAL]: nothing to say
+//   - [INTERNAL_ERROR]: the input value is NaN
+func (b *BiSpot) Step(x float64) SpotStatus {
+	if math.IsNaN(x) {
+		return INTERNAL_ERROR
+	}
+
+	// the value is given to its tail first, the other one sees a normal
+	// value unless the first one has discarded it
+	tail, other, high := b.Upper, b.Lower, true
+	if x < b.Upper.ExcessThreshold && x <= b.Lower.ExcessThreshold {
+		tail, other, high = b.Lower, b.Upper, false
+	}
+	n := tail.N
+	status := tail.Step(x)
+	if tail.N > n {
+		other.Step(x)
+	}
+
+	switch {
+	case status == ANOMALY && high:
+		return ANOMALY_HIGH
+	case status == ANOMALY:
+		return ANOMALY_LOW
+	case status == EXCESS && high:
+		return EXCESS_HIGH
+	case status == EXCESS:
+		return EXCESS_LOW
+	}
+	return NORMAL
+}
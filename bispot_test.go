@@ -0,0 +1,190 @@
+package gospot
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestBiSpotUniform(t *testing.T) {
+	q := 1e-5
+	level := 0.98
+	maxExcess := uint64(1000)
+
+	b, err := NewBiSpot(q, q, true, level, maxExcess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainingSize := uint64(float64(maxExcess) / (1 - level))
+	if err := b.Fit(uniform(trainingSize)); err != nil {
+		t.Fatal(err)
+	}
+	if b.Lower.ExcessThreshold >= b.Upper.ExcessThreshold {
+		t.Errorf("bad excess thresholds: %v >= %v", b.Lower.ExcessThreshold, b.Upper.ExcessThreshold)
+	}
+	if b.Lower.AnomalyThreshold >= b.Lower.ExcessThreshold {
+		t.Errorf("bad lower anomaly threshold: %v >= %v", b.Lower.AnomalyThreshold, b.Lower.ExcessThreshold)
+	}
+	if b.Upper.AnomalyThreshold <= b.Upper.ExcessThreshold {
+		t.Errorf("bad upper anomaly threshold: %v <= %v", b.Upper.AnomalyThreshold, b.Upper.ExcessThreshold)
+	}
+
+	counts := make(map[SpotStatus]int)
+	testSize := 10 * trainingSize
+	for _, x := range uniform(testSize) {
+		counts[b.Step(x)]++
+	}
+
+	for _, status := range []SpotStatus{ANOMALY, EXCESS, INTERNAL_ERROR} {
+		if counts[status] != 0 {
+			t.Errorf("one-sided status %d returned %d times", status, counts[status])
+		}
+	}
+	for _, status := range []SpotStatus{ANOMALY_HIGH, ANOMALY_LOW} {
+		r := float64(counts[status]) / float64(testSize)
+		if math.Abs(r-q) > 2*q {
+			t.Errorf("Anomaly ratio (%d): %E", status, r)
+		}
+	}
+	if counts[EXCESS_HIGH] == 0 || counts[EXCESS_LOW] == 0 {
+		t.Errorf("no excess found: %v", counts)
+	}
+	if b.Upper.N != b.Lower.N {
+		t.Errorf("tails have not seen the same data: %d != %d", b.Upper.N, b.Lower.N)
+	}
+	if b.Step(math.NaN()) != INTERNAL_ERROR {
+		t.Errorf("NaN must return an internal error")
+	}
+}
+
+func TestBiSpotStatus(t *testing.T) {
+	b, err := NewBiSpot(1e-4, 1e-4, true, 0.98, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	n := b.Upper.N
+	if s := b.Step(b.Upper.AnomalyThreshold + 1); s != ANOMALY_HIGH {
+		t.Errorf("bad status: %d instead of %d", s, ANOMALY_HIGH)
+	}
+	if s := b.Step(b.Lower.AnomalyThreshold - 1); s != ANOMALY_LOW {
+		t.Errorf("bad status: %d instead of %d", s, ANOMALY_LOW)
+	}
+	if b.Upper.N != n || b.Lower.N != n {
+		t.Errorf("anomalies must not be counted: %d, %d != %d", b.Upper.N, b.Lower.N, n)
+	}
+	if s := b.Step(b.Upper.ExcessThreshold + 1e-6); s != EXCESS_HIGH {
+		t.Errorf("bad status: %d instead of %d", s, EXCESS_HIGH)
+	}
+	if s := b.Step(b.Lower.ExcessThreshold - 1e-6); s != EXCESS_LOW {
+		t.Errorf("bad status: %d instead of %d", s, EXCESS_LOW)
+	}
+	if s := b.Step(0.0); s != NORMAL {
+		t.Errorf("bad status: %d instead of %d", s, NORMAL)
+	}
+	if b.Upper.N != n+3 || b.Lower.N != n+3 {
+		t.Errorf("bad counters: %d, %d != %d", b.Upper.N, b.Lower.N, n+3)
+	}
+}
+
+func TestBiSpotJSON(t *testing.T) {
+	b, err := NewBiSpot(1e-4, 1e-3, true, 0.98, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &BiSpot{}
+	if err := json.Unmarshal(raw, other); err != nil {
+		t.Fatal(err)
+	}
+	if other.Lower.Q != 1e-4 || other.Upper.Q != 1e-3 {
+		t.Errorf("bad decision probabilities: %v, %v", other.Lower.Q, other.Upper.Q)
+	}
+	if !other.Lower.Low || other.Upper.Low {
+		t.Errorf("bad tail orientation")
+	}
+	if other.Upper.AnomalyThreshold != b.Upper.AnomalyThreshold || other.Lower.AnomalyThreshold != b.Lower.AnomalyThreshold {
+		t.Errorf("anomaly thresholds not restored")
+	}
+}
+
+func TestBiSpotFitState(t *testing.T) {
+	b, err := NewBiSpot(1e-4, 1e-4, true, 0.98, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []*Spot{b.Upper, b.Lower} {
+		s.Refit = &RefitPolicy{Tolerance: 0.1}
+		if err := s.SetTiers([]float64{1e-3}, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetConfidence(&ConfidencePolicy{Level: 0.9}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Fit(gaussian(20000)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []*Spot{b.Upper, b.Lower} {
+		if s.Refit.LastMean != s.Tail.Peaks.Mean() {
+			t.Errorf("the fit must be recorded by the refit policy: %v != %v", s.Refit.LastMean, s.Tail.Peaks.Mean())
+		}
+		if math.IsNaN(s.TierThresholds[0]) {
+			t.Errorf("the tier thresholds must be computed")
+		}
+		if s.ThresholdInterval == nil || math.IsNaN(s.ThresholdInterval.Lower) {
+			t.Errorf("the threshold interval must be computed: %+v", s.ThresholdInterval)
+		}
+	}
+}
+
+func TestBiSpotAdaptive(t *testing.T) {
+	b, err := NewBiSpot(1e-3, 1e-3, true, 0.98, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []*Spot{b.Upper, b.Lower} {
+		s.SetAdaptive(true)
+		s.Decluster = 3
+	}
+	if err := b.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the discarded anomalies must not be seen by any tail
+	for i, x := range gaussian(20_000) {
+		switch i % 500 {
+		case 0:
+			x = b.Lower.AnomalyThreshold - 1
+		case 250:
+			x = b.Upper.AnomalyThreshold + 1
+		}
+		b.Step(x)
+	}
+	if b.Upper.N != b.Lower.N {
+		t.Errorf("both tails must count the same data: %d != %d", b.Upper.N, b.Lower.N)
+	}
+	if b.Upper.Tracker.Count != b.Lower.Tracker.Count {
+		t.Errorf("both trackers must see the same data: %d != %d", b.Upper.Tracker.Count, b.Lower.Tracker.Count)
+	}
+
+	// a lower anomaly does not close the cluster of the upper tail
+	b.Step(b.Upper.ExcessThreshold + 1e-6)
+	for i := 0; i < 5; i++ {
+		b.Step(b.Lower.AnomalyThreshold - 1)
+	}
+	if b.Upper.ClusterSize == 0 {
+		t.Errorf("the cluster of the upper tail must still be open")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/asiffer/gospot/prometheus"
+)
+
+// parseTimeFlag parses a RFC3339 date or a unix timestamp (in seconds)
+func parseTimeFlag(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	ts, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad time %q (RFC3339 or unix timestamp expected)", s)
+	}
+	return time.UnixMilli(int64(ts * 1000)), nil
+}
+
+type anomalyRecord struct {
+	Time             time.Time         `json:"time"`
+	Labels           map[string]string `json:"labels"`
+	Value            float64           `json:"value"`
+	AnomalyThreshold float64           `json:"anomaly_threshold"`
+	Probability      float64           `json:"probability"`
+}
+
+func backfill(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gospot backfill [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Run a range query against Prometheus and a detector over every returned\n")
+		fmt.Fprintf(fs.Output(), "series. Each detector is trained on the first samples of its series.\n")
+		fmt.Fprintf(fs.Output(), "The anomalies are written as JSON lines. With -rules, static alerting\n")
+		fmt.Fprintf(fs.Output(), "rules are also built from the final thresholds.\n\n")
+		fs.PrintDefaults()
+	}
+	config := spotFlags(fs)
+	train := fs.Int("train", 1000, "number of samples used to fit each detector")
+	url := fs.String("url", "http://localhost:9090", "Prometheus base URL")
+	query := fs.String("query", "", "PromQL expression")
+	startFlag := fs.String("start", "", "start of the range (RFC3339 or unix timestamp, default: 24h before the end)")
+	endFlag := fs.String("end", "", "end of the range (RFC3339 or unix timestamp, default: now)")
+	step := fs.Duration("step", time.Minute, "query resolution")
+	timeout := fs.Duration("timeout", time.Minute, "query timeout")
+	rules := fs.String("rules", "", "write Prometheus alerting rules built from the fitted detectors to this file")
+	metric := fs.String("metric", "", "metric name used in the rules (default: the __name__ label of each series); it must hold the values of the query, e.g. a recording rule of it")
+	alert := fs.String("alert", "GospotAnomaly", "name of the alerts")
+	alertFor := fs.Duration("for", 0, "how long the threshold must be crossed before an alert fires")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("too many arguments")
+	}
+	if *query == "" {
+		return fmt.Errorf("a query must be given")
+	}
+	if *step <= 0 {
+		return fmt.Errorf("step must be positive")
+	}
+
+	end := time.Now()
+	if *endFlag != "" {
+		t, err := parseTimeFlag(*endFlag)
+		if err != nil {
+			return err
+		}
+		end = t
+	}
+	start := end.Add(-24 * time.Hour)
+	if *startFlag != "" {
+		t, err := parseTimeFlag(*startFlag)
+		if err != nil {
+			return err
+		}
+		start = t
+	}
+	if !start.Before(end) {
+		return fmt.Errorf("start must be before end")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	client := &prometheus.Client{URL: *url}
+	series, err := client.QueryRange(ctx, *query, start, end, *step)
+	if err != nil {
+		return err
+	}
+
+	results, err := prometheus.Backfill(series, *config, *train)
+	if err != nil {
+		return err
+	}
+
+	group := prometheus.NewRuleGroup("gospot", *alert)
+	group.For = *alertFor
+	encoder := json.NewEncoder(stdout)
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "gospot backfill: skipping %v: %v\n", r.Labels, r.Err)
+			continue
+		}
+		if *rules != "" {
+			if err := group.Add(*metric, r.Labels, r.Spot); err != nil {
+				fmt.Fprintf(os.Stderr, "gospot backfill: no rule for %v: %v\n", r.Labels, err)
+			}
+		}
+		for _, a := range r.Anomalies {
+			if err := encoder.Encode(anomalyRecord{
+				Time:             a.Time,
+				Labels:           r.Labels,
+				Value:            a.Value,
+				AnomalyThreshold: a.AnomalyThreshold,
+				Probability:      a.Probability,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if *rules != "" {
+		return writeRules(*rules, group)
+	}
+	return nil
+}
+
+// writeRules writes then renames the rules file so that Prometheus never
+// reads a partial file
+func writeRules(path string, group *prometheus.RuleGroup) error {
+	var buf bytes.Buffer
+	if _, err := group.WriteTo(&buf); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func queryRangeServer(t *testing.T, start time.Time, values []float64) *httptest.Server {
+	t.Helper()
+	points := make([]string, len(values))
+	for i, x := range values {
+		points[i] = fmt.Sprintf(`[%d,"%v"]`, start.Unix()+int64(60*i), x)
+	}
+	body := fmt.Sprintf(`{"status":"success","data":{"resultType":"matrix","result":[`+
+		`{"metric":{"job":"api"},"values":[%s]},`+
+		`{"metric":{"job":"short"},"values":[[%d,"1"]]}]}}`, strings.Join(points, ","), start.Unix())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query_range" || r.FormValue("query") != "rate(errors[5m])" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"unexpected request"}`)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBackfill(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0).UTC()
+	values := make([]float64, 20_000)
+	for i := range values {
+		values[i] = rand.NormFloat64()
+	}
+	values[15_000] = 50
+	srv := queryRangeServer(t, start, values)
+
+	var out bytes.Buffer
+	args := []string{
+		"-url", srv.URL,
+		"-query", "rate(errors[5m])",
+		"-start", start.Format(time.RFC3339),
+		"-end", fmt.Sprint(start.Unix() + 60*20_000),
+		"-train", "5000",
+	}
+	if err := backfill(args, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record anomalyRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("bad line %q: %v", line, err)
+		}
+		if record.Labels["job"] != "api" {
+			t.Errorf("bad labels: %v", record.Labels)
+		}
+		if record.Value == 50 {
+			found = true
+			if !record.Time.Equal(start.Add(15_000 * time.Minute)) {
+				t.Errorf("bad time: %v", record.Time)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("anomaly not found:\n%s", out.String())
+	}
+
+	// the server rejects other queries
+	args[3] = "up"
+	if err := backfill(args, nil, &out); err == nil {
+		t.Errorf("must return an error on query failure")
+	}
+}
+
+func TestBackfillErrors(t *testing.T) {
+	cases := map[string][]string{
+		"no query":     {},
+		"bad start":    {"-query", "up", "-start", "yesterday"},
+		"bad range":    {"-query", "up", "-start", "10", "-end", "5"},
+		"bad step":     {"-query", "up", "-step", "0s"},
+		"bad q":        {"-query", "up", "-q", "0.5"},
+		"too many arg": {"-query", "up", "x"},
+	}
+	for name, args := range cases {
+		if err := backfill(args, nil, &bytes.Buffer{}); err == nil {
+			t.Errorf("%s: must return an error", name)
+		}
+	}
+}
+
+func TestBackfillRules(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0).UTC()
+	values := make([]float64, 10_000)
+	for i := range values {
+		values[i] = rand.NormFloat64()
+	}
+	srv := queryRangeServer(t, start, values)
+
+	path := filepath.Join(t.TempDir(), "gospot.rules.yml")
+	args := []string{
+		"-url", srv.URL,
+		"-query", "rate(errors[5m])",
+		"-start", fmt.Sprint(start.Unix()),
+		"-end", fmt.Sprint(start.Unix() + 60*10_000),
+		"-train", "5000",
+		"-rules", path,
+		"-metric", "job:errors:rate5m",
+		"-for", "10m",
+	}
+	if err := backfill(args, nil, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(raw)
+	// the short series is skipped
+	if strings.Count(out, "- alert: \"GospotAnomaly\"") != 1 ||
+		!strings.Contains(out, `expr: "job:errors:rate5m{job=\"api\"} > `) ||
+		!strings.Contains(out, "for: 10m") {
+		t.Errorf("bad rules:\n%s", out)
+	}
+}
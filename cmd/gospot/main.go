@@ -0,0 +1,64 @@
+// Command gospot runs the SPOT algorithm from the command line.
+//
+// Usage:
+//
+//	gospot <command> [flags]
+//
+// The commands are:
+//
+//	run       run a detector over a CSV or JSON-lines stream
+//	serve     expose detectors over a REST API
+//	backfill  run detectors over a Prometheus range query
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	name  string
+	short string
+	run   func(args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = []command{
+	{name: "run", short: "run a detector over a CSV or JSON-lines stream", run: run},
+	{name: "serve", short: "expose detectors over a REST API", run: serve},
+	{name: "backfill", short: "run detectors over a Prometheus range query", run: backfill},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: gospot <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.short)
+	}
+	fmt.Fprintf(w, "\nRun 'gospot <command> -h' for the flags of a command.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "gospot %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	if name == "-h" || name == "-help" || name == "--help" || name == "help" {
+		usage(os.Stdout)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "gospot: unknown command %q\n", name)
+	usage(os.Stderr)
+	os.Exit(2)
+}
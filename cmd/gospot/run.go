@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/asiffer/gospot"
+)
+
+// spotFlags registers the parameters of gospot.NewSpot on a flag set
+func spotFlags(fs *flag.FlagSet) *gospot.SpotConfig {
+	config := &gospot.SpotConfig{}
+	fs.Float64Var(&config.Q, "q", 1e-4, "decision probability (anomalies have a probability lower than q)")
+	fs.BoolVar(&config.Low, "low", false, "watch the lower tail instead of the upper one")
+	fs.BoolVar(&config.DiscardAnomalies, "discard", true, "do not include anomalies in the model")
+	fs.Float64Var(&config.Level, "level", 0.98, "excess level (high quantile that delimits the tail)")
+	fs.Uint64Var(&config.MaxExcess, "max-excess", 200, "maximum number of excesses kept to model the tail")
+	return config
+}
+
+// valueReader returns the values of a stream one by one (io.EOF at the end)
+type valueReader interface {
+	Next() (float64, error)
+}
+
+// csvReader reads a column of a CSV stream
+type csvReader struct {
+	r      *csv.Reader
+	column string
+	header bool
+	index  int
+}
+
+func newCSVReader(r io.Reader, column string, header bool) (*csvReader, error) {
+	c := &csvReader{r: csv.NewReader(r), column: column, header: header}
+	c.r.ReuseRecord = true
+	c.r.FieldsPerRecord = -1
+
+	if !header {
+		index, err := strconv.Atoi(column)
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("column must be an index when there is no header: %q", column)
+		}
+		c.index = index
+		return c, nil
+	}
+
+	names, err := c.r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	c.index = -1
+	for i, name := range names {
+		if strings.TrimSpace(name) == column {
+			c.index = i
+			break
+		}
+	}
+	if c.index < 0 {
+		// the column may also be given by its index
+		index, err := strconv.Atoi(column)
+		if err != nil || index < 0 || index >= len(names) {
+			return nil, fmt.Errorf("column %q not found in header", column)
+		}
+		c.index = index
+	}
+	return c, nil
+}
+
+func (c *csvReader) Next() (float64, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		return 0, err
+	}
+	line, _ := c.r.FieldPos(0)
+	if c.index >= len(record) {
+		return 0, fmt.Errorf("line %d: missing column %s", line, c.column)
+	}
+	x, err := strconv.ParseFloat(strings.TrimSpace(record[c.index]), 64)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: %w", line, err)
+	}
+	return x, nil
+}
+
+// jsonReader reads a field of a JSON-lines stream
+type jsonReader struct {
+	s    *bufio.Scanner
+	path []string
+	line int
+}
+
+func newJSONReader(r io.Reader, field string) (*jsonReader, error) {
+	if field == "" {
+		return nil, fmt.Errorf("field must not be empty")
+	}
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &jsonReader{s: s, path: strings.Split(field, ".")}, nil
+}
+
+func (j *jsonReader) Next() (float64, error) {
+	for j.s.Scan() {
+		j.line++
+		raw := strings.TrimSpace(j.s.Text())
+		if raw == "" {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			return 0, fmt.Errorf("line %d: %w", j.line, err)
+		}
+		for _, key := range j.path {
+			object, ok := v.(map[string]interface{})
+			if !ok {
+				return 0, fmt.Errorf("line %d: field %q not found", j.line, strings.Join(j.path, "."))
+			}
+			if v, ok = object[key]; !ok {
+				return 0, fmt.Errorf("line %d: field %q not found", j.line, strings.Join(j.path, "."))
+			}
+		}
+		switch x := v.(type) {
+		case float64:
+			return x, nil
+		case string:
+			f, err := strconv.ParseFloat(x, 64)
+			if err != nil {
+				return 0, fmt.Errorf("line %d: %w", j.line, err)
+			}
+			return f, nil
+		default:
+			return 0, fmt.Errorf("line %d: field %q is not a number", j.line, strings.Join(j.path, "."))
+		}
+	}
+	if err := j.s.Err(); err != nil {
+		return 0, err
+	}
+	return 0, io.EOF
+}
+
+// formatFloat formats a float for the output (empty if NaN)
+func formatFloat(x float64) string {
+	if math.IsNaN(x) {
+		return ""
+	}
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
+
+// tailProbability returns the probability of x if it lies in the tail (NaN otherwise)
+func tailProbability(spot *gospot.Spot, x float64) float64 {
+	d := x - spot.ExcessThreshold
+	if spot.Low {
+		d = -d
+	}
+	if d < 0 {
+		return math.NaN()
+	}
+	return spot.Probability(x)
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gospot run [flags] [file]\n\n")
+		fmt.Fprintf(fs.Output(), "Train a detector on the first values of the stream (stdin if no file\n")
+		fmt.Fprintf(fs.Output(), "is given) and step the next ones. It outputs a CSV row per input value.\n\n")
+		fs.PrintDefaults()
+	}
+	config := spotFlags(fs)
+	train := fs.Int("train", 1000, "number of values used to fit the detector")
+	format := fs.String("format", "csv", "input format: csv or jsonl")
+	column := fs.String("column", "0", "csv: column name (with -header) or index")
+	header := fs.Bool("header", false, "csv: the first row is a header")
+	field := fs.String("field", "value", "jsonl: path of the value (dot separated)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	spot, err := config.New()
+	if err != nil {
+		return err
+	}
+	if *train <= 0 {
+		return fmt.Errorf("the number of training values must be positive")
+	}
+
+	in := stdin
+	if fs.NArg() > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+	if fs.NArg() == 1 && fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var values valueReader
+	switch *format {
+	case "csv":
+		values, err = newCSVReader(in, *column, *header)
+	case "jsonl":
+		values, err = newJSONReader(in, *field)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(stdout)
+	defer out.Flush()
+	if err := out.Write([]string{"value", "status", "anomaly_threshold", "excess_threshold", "probability"}); err != nil {
+		return err
+	}
+	write := func(x float64, status string, anomalyThreshold, excessThreshold, p float64) error {
+		return out.Write([]string{
+			formatFloat(x),
+			status,
+			formatFloat(anomalyThreshold),
+			formatFloat(excessThreshold),
+			formatFloat(p),
+		})
+	}
+
+	training := make([]float64, 0, *train)
+	for len(training) < *train {
+		x, err := values.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		training = append(training, x)
+	}
+	if err := spot.Fit(training); err != nil {
+		return fmt.Errorf("fit: %w", err)
+	}
+	for _, x := range training {
+		if err := write(x, "TRAINING", spot.AnomalyThreshold, spot.ExcessThreshold, tailProbability(spot, x)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		x, err := values.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// thresholds and probability are the ones used to take the decision
+		anomalyThreshold, excessThreshold, p := spot.AnomalyThreshold, spot.ExcessThreshold, tailProbability(spot, x)
+		status := spot.Step(x)
+		if err := write(x, status.String(), anomalyThreshold, excessThreshold, p); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func gaussianLines(size int, format func(i int, x float64) string) string {
+	var b strings.Builder
+	for i := 0; i < size; i++ {
+		b.WriteString(format(i, rand.NormFloat64()))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func runRecords(t *testing.T, args []string, input string) [][]string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := run(args, strings.NewReader(input), &out); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func checkRecords(t *testing.T, records [][]string, train int, total int) {
+	t.Helper()
+	if len(records) != total+1 {
+		t.Fatalf("bad number of rows: %d instead of %d", len(records), total+1)
+	}
+	if strings.Join(records[0], ",") != "value,status,anomaly_threshold,excess_threshold,probability" {
+		t.Errorf("bad header: %v", records[0])
+	}
+	counts := make(map[string]int)
+	for _, r := range records[1:] {
+		counts[r[1]]++
+		if r[2] == "" || r[3] == "" {
+			t.Fatalf("missing thresholds: %v", r)
+		}
+	}
+	if counts["TRAINING"] != train {
+		t.Errorf("bad number of training rows: %d", counts["TRAINING"])
+	}
+	if counts["EXCESS"] == 0 || counts["NORMAL"] == 0 {
+		t.Errorf("bad statuses: %v", counts)
+	}
+}
+
+func TestRunCSV(t *testing.T) {
+	input := "time,latency\n" + gaussianLines(20_000, func(i int, x float64) string { return fmt.Sprintf("%d,%v", i, x) })
+	records := runRecords(t, []string{"-header", "-column", "latency", "-train", "5000"}, input)
+	checkRecords(t, records, 5000, 20_000)
+
+	// the column can be selected by index
+	records = runRecords(t, []string{"-header", "-column", "1", "-train", "5000", "-low"}, input)
+	checkRecords(t, records, 5000, 20_000)
+}
+
+func TestRunCSVFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	input := gaussianLines(20_000, func(i int, x float64) string { return fmt.Sprint(x) })
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	records := runRecords(t, []string{"-train", "5000", "-q", "1e-3", path}, "")
+	checkRecords(t, records, 5000, 20_000)
+}
+
+func TestRunJSONLines(t *testing.T) {
+	input := gaussianLines(20_000, func(i int, x float64) string {
+		return fmt.Sprintf(`{"ts": %d, "metric": {"value": %v}}`, i, x)
+	})
+	records := runRecords(t, []string{"-format", "jsonl", "-field", "metric.value", "-train", "5000"}, input)
+	checkRecords(t, records, 5000, 20_000)
+
+	// the probability is given for the values in the tail
+	for _, r := range records[5001:] {
+		if (r[1] == "NORMAL") != (r[4] == "") {
+			t.Fatalf("bad probability: %v", r)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	cases := map[string]struct {
+		args  []string
+		input string
+	}{
+		"bad level":      {[]string{"-level", "1.5"}, "1\n"},
+		"bad format":     {[]string{"-format", "xml"}, "1\n"},
+		"unknown column": {[]string{"-header", "-column", "x"}, "a,b\n1,2\n"},
+		"not a number":   {[]string{"-train", "2"}, "1\nx\n"},
+		"missing field":  {[]string{"-format", "jsonl", "-field", "v"}, `{"w": 1}` + "\n"},
+		"too few values": {[]string{"-train", "100"}, "1\n2\n"},
+	}
+	for name, c := range cases {
+		var out bytes.Buffer
+		if err := run(c.args, strings.NewReader(c.input), &out); err == nil {
+			t.Errorf("%s: must return an error", name)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/asiffer/gospot/server"
+)
+
+func serve(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gospot serve [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Expose detectors over a REST API.\n\n")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", "localhost:8080", "listening address")
+	dir := fs.String("dir", "", "directory where the detectors are saved (in memory only if empty)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("too many arguments")
+	}
+
+	s, err := server.New(*dir)
+	if err != nil {
+		return err
+	}
+	log.Printf("listening on %s", *addr)
+	return http.ListenAndServe(*addr, s)
+}
@@ -0,0 +1,288 @@
+package gospot
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// IntervalMethod tells how a confidence interval is computed
+type IntervalMethod string
+
+const (
+	// IntervalDelta uses the delta method with the observed Fisher
+	// information of the GPD likelihood (default)
+	IntervalDelta IntervalMethod = "delta"
+	// IntervalBootstrap uses a parametric bootstrap of the peaks
+	IntervalBootstrap IntervalMethod = "bootstrap"
+)
+
+// DefaultBootstrapSamples is the number of bootstrap samples used when a
+// [ConfidencePolicy] does not set it
+const DefaultBootstrapSamples = 200
+
+// Interval is a confidence interval
+type Interval struct {
+	// Lower bound
+	Lower float64 `json:"lower"`
+	// Upper bound
+	Upper float64 `json:"upper"`
+	// Confidence level (like 0.95)
+	Level float64 `json:"level"`
+}
+
+// Contains tells whether x lies in the interval
+func (i Interval) Contains(x float64) bool {
+	return i.Lower <= x && x <= i.Upper
+}
+
+// ConfidencePolicy tells how the confidence interval of the anomaly
+// threshold is maintained (see [Spot.SetConfidence])
+type ConfidencePolicy struct {
+	// Confidence level, in (0, 1)
+	Level float64 `json:"level"`
+	// Method of the interval (empty means [IntervalDelta])
+	Method IntervalMethod `json:"method,omitempty"`
+	// Number of bootstrap samples (0 means [DefaultBootstrapSamples])
+	Samples int `json:"samples,omitempty"`
+}
+
+// check checks the policy
+func (p *ConfidencePolicy) check() error {
+	if !(p.Level > 0 && p.Level < 1) {
+		return fmt.Errorf("confidence level must be in (0, 1)")
+	}
+	switch p.Method {
+	case "", IntervalDelta, IntervalBootstrap:
+	default:
+		return fmt.Errorf("unknown interval method %q", p.Method)
+	}
+	if p.Samples < 0 {
+		return fmt.Errorf("the number of bootstrap samples must be non-negative")
+	}
+	return nil
+}
+
+// SetConfidence makes the detector maintain a confidence interval of its
+// anomaly threshold (nil disables it). A delta interval is computed on
+// every fit of the tail. A bootstrap interval, far more expensive, is only
+// computed by [Spot.Fit], [Spot.SetConfidence] and [Spot.RefreshInterval]
+// (the last one is kept in the meantime). The policy is copied.
+func (spot *Spot) SetConfidence(policy *ConfidencePolicy) error {
+	if policy == nil {
+		spot.Confidence = nil
+		spot.ThresholdInterval = nil
+		return nil
+	}
+	if err := policy.check(); err != nil {
+		return err
+	}
+	p := *policy
+	spot.Confidence = &p
+	spot.ThresholdInterval = nil
+	if !math.IsNaN(spot.AnomalyThreshold) {
+		spot.RefreshInterval()
+	}
+	return nil
+}
+
+// RefreshInterval computes the confidence interval of the anomaly
+// threshold according to the policy and stores it. The bounds are NaN
+// when it cannot be computed.
+func (spot *Spot) RefreshInterval() error {
+	if spot.Confidence == nil {
+		return fmt.Errorf("the detector has no confidence policy")
+	}
+	interval, err := spot.computeInterval(*spot.Confidence)
+	spot.ThresholdInterval = &interval
+	return err
+}
+
+// checkConfidence checks the consistency of the confidence state
+func (spot *Spot) checkConfidence() error {
+	if spot.Confidence == nil {
+		if spot.ThresholdInterval != nil {
+			return fmt.Errorf("spot: threshold interval without confidence policy")
+		}
+		return nil
+	}
+	return spot.Confidence.check()
+}
+
+// updateInterval computes the interval of the anomaly threshold after a
+// fit of the tail. A bootstrap interval is kept as is.
+func (spot *Spot) updateInterval() {
+	p := spot.Confidence
+	if p == nil {
+		spot.ThresholdInterval = nil
+		return
+	}
+	if p.Method == IntervalBootstrap {
+		return
+	}
+	interval, _ := spot.computeInterval(*p)
+	spot.ThresholdInterval = &interval
+}
+
+// updateBootstrap computes the interval of a bootstrap policy (the other
+// ones are computed on every fit of the tail)
+func (spot *Spot) updateBootstrap() {
+	if spot.Confidence != nil && spot.Confidence.Method == IntervalBootstrap {
+		spot.RefreshInterval()
+	}
+}
+
+// computeInterval computes the interval of the anomaly threshold with the
+// given policy. The bounds are NaN when it cannot be computed.
+func (spot *Spot) computeInterval(p ConfidencePolicy) (Interval, error) {
+	var interval Interval
+	var err error
+	if p.Method == IntervalBootstrap {
+		samples := p.Samples
+		if samples == 0 {
+			samples = DefaultBootstrapSamples
+		}
+		interval, err = spot.BootstrapInterval(p.Level, samples, nil)
+	} else {
+		interval, err = spot.DeltaInterval(p.Level)
+	}
+	if err != nil {
+		interval = Interval{Lower: math.NaN(), Upper: math.NaN(), Level: p.Level}
+	}
+	return interval, err
+}
+
+// zScore returns the quantile of the standard normal distribution such
+// that a centered interval has the given confidence level
+func zScore(level float64) float64 {
+	return math.Sqrt2 * math.Erfinv(level)
+}
+
+// checkFitted checks that a confidence interval can be computed
+func (spot *Spot) checkFitted(level float64) error {
+	if !(level > 0 && level < 1) {
+		return fmt.Errorf("confidence level must be in (0, 1)")
+	}
+	if spot.N == 0 || spot.Nt == 0 || spot.Tail.Peaks.Size() < 2 || !(spot.Tail.Sigma > 0) {
+		return fmt.Errorf("the detector is not fitted")
+	}
+	return nil
+}
+
+// thresholdInterval returns the interval of the threshold given the one
+// of the tail quantile (the excess threshold is taken as exact)
+func (spot *Spot) thresholdInterval(lower, upper, level float64) Interval {
+	a := spot.ExcessThreshold + spot.upDown()*lower
+	b := spot.ExcessThreshold + spot.upDown()*upper
+	if spot.Low {
+		a, b = b, a
+	}
+	return Interval{Lower: a, Upper: b, Level: level}
+}
+
+// DeltaInterval computes a confidence interval of the anomaly threshold
+// by the delta method. The covariance of the GPD parameters is the
+// inverse of the observed Fisher information, i.e. of the Hessian of
+// [Peaks.LogLikelihood] at the fitted parameters. The excess threshold and
+// the ratio Nt/N are taken as exact.
+func (spot *Spot) DeltaInterval(level float64) (Interval, error) {
+	if err := spot.checkFitted(level); err != nil {
+		return Interval{}, err
+	}
+	peaks := spot.Tail.Peaks
+	gamma, sigma := spot.Tail.Gamma, spot.Tail.Sigma
+
+	// observed information by central differences
+	hg, hs := 1e-4, 1e-4*sigma
+	l := func(dg, ds float64) float64 {
+		return peaks.LogLikelihood(gamma+dg, sigma+ds)
+	}
+	l0 := l(0, 0)
+	igg := -(l(hg, 0) - 2*l0 + l(-hg, 0)) / (hg * hg)
+	iss := -(l(0, hs) - 2*l0 + l(0, -hs)) / (hs * hs)
+	igs := -(l(hg, hs) - l(hg, -hs) - l(-hg, hs) + l(-hg, -hs)) / (4 * hg * hs)
+	det := igg*iss - igs*igs
+	if math.IsNaN(det) || det <= 0 || igg <= 0 {
+		return Interval{}, fmt.Errorf("the observed information is not positive definite")
+	}
+	vgg, vss, vgs := iss/det, igg/det, -igs/det
+
+	// gradient of the tail quantile sigma/gamma*(r^-gamma - 1)
+	r := spot.Q * float64(spot.N) / float64(spot.Nt)
+	lr := math.Log(r)
+	var dg, ds float64
+	if math.Abs(gamma) < 1e-8 {
+		dg = sigma * lr * lr / 2
+		ds = -lr
+	} else {
+		rg := math.Pow(r, -gamma)
+		ds = (rg - 1) / gamma
+		dg = -sigma*(rg-1)/(gamma*gamma) - sigma*rg*lr/gamma
+	}
+	variance := dg*dg*vgg + 2*dg*ds*vgs + ds*ds*vss
+	if math.IsNaN(variance) || variance < 0 {
+		return Interval{}, fmt.Errorf("invalid variance of the threshold")
+	}
+
+	s := float64(spot.Nt) / float64(spot.N)
+	center := spot.Tail.Quantile(s, spot.Q)
+	half := zScore(level) * math.Sqrt(variance)
+	return spot.thresholdInterval(center-half, center+half, level), nil
+}
+
+// BootstrapInterval computes a percentile confidence interval of the
+// anomaly threshold by parametric bootstrap: samples sets of peaks are
+// drawn from the fitted GPD and fitted with the estimators of the tail.
+// A nil rng uses a source seeded with N so that the interval only
+// depends on the state of the detector. The excess threshold and the
+// ratio Nt/N are taken as exact.
+func (spot *Spot) BootstrapInterval(level float64, samples int, rng *rand.Rand) (Interval, error) {
+	if err := spot.checkFitted(level); err != nil {
+		return Interval{}, err
+	}
+	if samples < 2 {
+		return Interval{}, fmt.Errorf("at least 2 bootstrap samples are required")
+	}
+	if rng == nil {
+		rng = rand.New(rand.NewSource(int64(spot.N)))
+	}
+
+	n := spot.Tail.Peaks.Size()
+	s := float64(spot.Nt) / float64(spot.N)
+	model := spot.Tail
+	tail := &Tail{Estimators: model.Estimators, Selection: model.Selection}
+	quantiles := make([]float64, 0, samples)
+	for b := 0; b < samples; b++ {
+		tail.Peaks = NewPeaks(n)
+		tail.Gamma, tail.Sigma = math.NaN(), math.NaN()
+		for i := uint64(0); i < n; i++ {
+			// the tail quantile of probability u is a GPD draw
+			tail.Peaks.Push(model.Quantile(1, 1-rng.Float64()))
+		}
+		llhood := tail.Fit()
+		if !validEstimate(tail.Gamma, tail.Sigma, llhood) {
+			continue
+		}
+		if z := tail.Quantile(s, spot.Q); !math.IsNaN(z) && !math.IsInf(z, 0) {
+			quantiles = append(quantiles, z)
+		}
+	}
+	if len(quantiles) < samples/2 || len(quantiles) < 2 {
+		return Interval{}, fmt.Errorf("too many bootstrap fits have failed")
+	}
+
+	sort.Float64s(quantiles)
+	alpha := (1 - level) / 2
+	return spot.thresholdInterval(percentile(quantiles, alpha), percentile(quantiles, 1-alpha), level), nil
+}
+
+// percentile returns the p-quantile of sorted values by linear interpolation
+func percentile(sorted []float64, p float64) float64 {
+	x := p * float64(len(sorted)-1)
+	i := int(x)
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (x-float64(i))*(sorted[i+1]-sorted[i])
+}
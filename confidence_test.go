@@ -0,0 +1,323 @@
+package gospot
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// paretoSpot fits a detector on Pareto data and returns the true
+// anomaly threshold
+func paretoSpot(t *testing.T, alpha float64, size uint64) (*Spot, float64) {
+	spot, err := NewSpot(1e-3, false, true, 0.98, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spot.Fit(pareto(alpha, size)); err != nil {
+		t.Fatal(err)
+	}
+	return spot, math.Pow(spot.Q, -1/alpha)
+}
+
+func TestDeltaInterval(t *testing.T) {
+	spot, _ := paretoSpot(t, 3, 10000)
+	interval, err := spot.DeltaInterval(0.9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%+v (threshold %f)", interval, spot.AnomalyThreshold)
+	if !(interval.Lower < spot.AnomalyThreshold && spot.AnomalyThreshold < interval.Upper) {
+		t.Errorf("the interval %+v must contain the threshold %f", interval, spot.AnomalyThreshold)
+	}
+	if interval.Level != 0.9 {
+		t.Errorf("bad level: %f", interval.Level)
+	}
+
+	wider, _ := spot.DeltaInterval(0.99)
+	if wider.Upper-wider.Lower <= interval.Upper-interval.Lower {
+		t.Errorf("a higher level must widen the interval: %+v vs %+v", wider, interval)
+	}
+
+	for _, level := range []float64{0, 1, math.NaN()} {
+		if _, err := spot.DeltaInterval(level); err == nil {
+			t.Errorf("must return an error for level %f", level)
+		}
+	}
+	if _, err := defaultSpot().DeltaInterval(0.9); err == nil {
+		t.Errorf("must return an error when the detector is not fitted")
+	}
+}
+
+func TestIntervalCoverage(t *testing.T) {
+	alpha := 3.0
+	runs := 100
+	delta, bootstrap := 0, 0
+	for i := 0; i < runs; i++ {
+		spot, truth := paretoSpot(t, alpha, 10000)
+		if interval, err := spot.DeltaInterval(0.9); err == nil && interval.Contains(truth) {
+			delta++
+		}
+		if interval, err := spot.BootstrapInterval(0.9, 100, nil); err == nil && interval.Contains(truth) {
+			bootstrap++
+		}
+	}
+	t.Logf("coverage: delta=%d/%d bootstrap=%d/%d", delta, runs, bootstrap, runs)
+	// the excess threshold is taken as exact so the coverage is a bit lower
+	for name, c := range map[string]int{"delta": delta, "bootstrap": bootstrap} {
+		if c < 70 {
+			t.Errorf("%s interval coverage is too low: %d/%d", name, c, runs)
+		}
+	}
+}
+
+func TestBootstrapInterval(t *testing.T) {
+	spot, _ := paretoSpot(t, 3, 10000)
+	a, err := spot.BootstrapInterval(0.9, 100, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := spot.BootstrapInterval(0.9, 100, nil)
+	if a != b {
+		t.Errorf("the default source must be deterministic: %+v vs %+v", a, b)
+	}
+	if c, _ := spot.BootstrapInterval(0.9, 100, rand.New(rand.NewSource(42))); c == a {
+		t.Errorf("the source must be used")
+	}
+	d, _ := spot.DeltaInterval(0.9)
+	t.Logf("bootstrap %+v, delta %+v", a, d)
+	if !(a.Lower < spot.AnomalyThreshold && spot.AnomalyThreshold < a.Upper) {
+		t.Errorf("the interval %+v must contain the threshold %f", a, spot.AnomalyThreshold)
+	}
+	if _, err := spot.BootstrapInterval(0.9, 1, nil); err == nil {
+		t.Errorf("must return an error with less than 2 samples")
+	}
+}
+
+func TestLowInterval(t *testing.T) {
+	data := pareto(3, 10000)
+	for i := range data {
+		data[i] = -data[i]
+	}
+	spot, _ := NewSpot(1e-3, true, true, 0.98, 1000)
+	if err := spot.Fit(data); err != nil {
+		t.Fatal(err)
+	}
+	interval, err := spot.DeltaInterval(0.9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(interval.Lower < spot.AnomalyThreshold && spot.AnomalyThreshold < interval.Upper) {
+		t.Errorf("the interval %+v must contain the threshold %f", interval, spot.AnomalyThreshold)
+	}
+}
+
+func TestSetConfidence(t *testing.T) {
+	spot := defaultSpot()
+	for _, p := range []ConfidencePolicy{
+		{Level: 0},
+		{Level: 1},
+		{Level: 0.9, Method: "unknown"},
+		{Level: 0.9, Samples: -1},
+	} {
+		if err := spot.SetConfidence(&p); err == nil {
+			t.Errorf("must return an error on %+v", p)
+		}
+	}
+
+	policy := &ConfidencePolicy{Level: 0.9}
+	if err := spot.SetConfidence(policy); err != nil {
+		t.Fatal(err)
+	}
+	policy.Level = 0.5
+	if spot.Confidence.Level != 0.9 {
+		t.Errorf("the policy must be copied")
+	}
+	if spot.ThresholdInterval != nil {
+		t.Errorf("no interval before the fit")
+	}
+
+	if err := spot.Fit(gaussian(20000)); err != nil {
+		t.Fatal(err)
+	}
+	interval := spot.ThresholdInterval
+	if interval == nil || !(interval.Lower < spot.AnomalyThreshold && spot.AnomalyThreshold < interval.Upper) {
+		t.Fatalf("bad interval after the fit: %+v (threshold %f)", interval, spot.AnomalyThreshold)
+	}
+
+	// the interval follows the refits
+	for i := 0; i < 1000; i++ {
+		x := rand.NormFloat64()
+		result := spot.StepDetailed(x)
+		if result.ThresholdInterval != *interval {
+			t.Fatalf("the step must report the interval in force: %+v vs %+v", result.ThresholdInterval, interval)
+		}
+		interval = spot.ThresholdInterval
+	}
+
+	spot.Reset()
+	if spot.ThresholdInterval != nil || spot.Confidence == nil {
+		t.Errorf("reset must forget the interval but keep the policy")
+	}
+
+	spot.SetConfidence(nil)
+	if spot.Confidence != nil || spot.ThresholdInterval != nil {
+		t.Errorf("confidence must be disabled")
+	}
+	spot.Fit(gaussian(20000))
+	if r := spot.StepDetailed(0); r.ThresholdInterval.Level != 0 || !math.IsNaN(r.ThresholdInterval.Lower) {
+		t.Errorf("no interval without policy: %+v", r.ThresholdInterval)
+	}
+	if err := spot.RefreshInterval(); err == nil {
+		t.Errorf("must return an error without policy")
+	}
+}
+
+func TestConfidenceConfig(t *testing.T) {
+	c := SpotConfig{Q: 1e-4, Level: 0.98, MaxExcess: 500, Confidence: &ConfidencePolicy{Level: 0.95, Method: IntervalBootstrap, Samples: 50}}
+	spot, err := c.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spot.Fit(gaussian(10000)); err != nil {
+		t.Fatal(err)
+	}
+	interval := spot.ThresholdInterval
+	if interval == nil || interval.Level != 0.95 || !(interval.Lower < interval.Upper) {
+		t.Fatalf("bad bootstrap interval: %+v", interval)
+	}
+
+	// the bootstrap is not run on the refits
+	at := spot.AnomalyThreshold
+	if spot.Step(spot.ExcessThreshold+0.1) != EXCESS || spot.AnomalyThreshold == at {
+		t.Fatalf("the tail must be refitted")
+	}
+	if *spot.ThresholdInterval != *interval {
+		t.Errorf("the bootstrap interval must be kept across the refits")
+	}
+	if err := spot.RefreshInterval(); err != nil {
+		t.Fatal(err)
+	}
+	if *spot.ThresholdInterval == *interval {
+		t.Errorf("the bootstrap interval must be refreshed")
+	}
+
+	c.Confidence = &ConfidencePolicy{Level: 2}
+	if _, err := c.New(); err == nil {
+		t.Errorf("must return an error on an invalid policy")
+	}
+}
+
+func TestConfidenceSerialization(t *testing.T) {
+	spot := defaultSpot()
+	spot.SetConfidence(&ConfidencePolicy{Level: 0.9})
+	if err := spot.Fit(gaussian(20000)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(spot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON := &Spot{}
+	if err := json.Unmarshal(data, fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := spot.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBinary := &Spot{}
+	if err := fromBinary.UnmarshalBinary(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	for name, other := range map[string]*Spot{"json": fromJSON, "binary": fromBinary} {
+		if other.Confidence == nil || *other.Confidence != *spot.Confidence {
+			t.Errorf("%s: bad policy %+v", name, other.Confidence)
+		}
+		if other.ThresholdInterval == nil || *other.ThresholdInterval != *spot.ThresholdInterval {
+			t.Errorf("%s: bad interval %+v", name, other.ThresholdInterval)
+		}
+	}
+
+	// NaN bounds
+	spot.ThresholdInterval = &Interval{Lower: math.NaN(), Upper: math.NaN(), Level: 0.9}
+	data, err = json.Marshal(spot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(fromJSON.ThresholdInterval.Lower) || !math.IsNaN(fromJSON.ThresholdInterval.Upper) {
+		t.Errorf("NaN bounds must be kept: %+v", fromJSON.ThresholdInterval)
+	}
+
+	if err := json.Unmarshal([]byte(`{"tail":{"peaks":{"container":{"capacity":0,"data":[]}}},"threshold_interval":{"lower":0,"upper":1,"level":0.9}}`), &Spot{}); err == nil {
+		t.Errorf("must return an error on an interval without policy")
+	}
+}
+
+func TestSyncSpotRefreshInterval(t *testing.T) {
+	s := NewSyncSpot(defaultSpot())
+	if err := s.RefreshInterval(); err == nil {
+		t.Errorf("must return an error without policy")
+	}
+	s.Do(func(spot *Spot) {
+		spot.SetConfidence(&ConfidencePolicy{Level: 0.9, Method: IntervalBootstrap, Samples: 50})
+	})
+	if err := s.Fit(gaussian(20000)); err != nil {
+		t.Fatal(err)
+	}
+	var before Interval
+	s.View(func(spot *Spot) { before = *spot.ThresholdInterval })
+	for _, x := range gaussian(1000) {
+		s.Step(x)
+	}
+	if err := s.RefreshInterval(); err != nil {
+		t.Fatal(err)
+	}
+	s.View(func(spot *Spot) {
+		interval := *spot.ThresholdInterval
+		if interval == before || !interval.Contains(spot.AnomalyThreshold) {
+			t.Errorf("bad refreshed interval: %+v (threshold %f)", interval, spot.AnomalyThreshold)
+		}
+	})
+}
+
+func TestSyncSpotRefreshStale(t *testing.T) {
+	var s *SyncSpot
+	var x float64
+	stepped := false
+	// the estimator steps the detector during the bootstrap as a
+	// concurrent call would do
+	estimator := hookEstimator(t, func() {
+		if s != nil && !stepped {
+			stepped = true
+			s.Step(x)
+		}
+	})
+
+	spot := defaultSpot()
+	spot.Tail.SetEstimators([]string{estimator}, SelectMaxLikelihood)
+	spot.SetConfidence(&ConfidencePolicy{Level: 0.9, Method: IntervalBootstrap, Samples: 20})
+	if err := spot.Fit(gaussian(20000)); err != nil {
+		t.Fatal(err)
+	}
+	// a fresh bootstrap differs from the one of the fit
+	spot.Step(0.0)
+	before := *spot.ThresholdInterval
+	threshold := spot.AnomalyThreshold
+	x = spot.ExcessThreshold + 0.1
+
+	s = NewSyncSpot(spot)
+	s.RefreshInterval()
+	s.View(func(spot *Spot) {
+		if !stepped || spot.AnomalyThreshold == threshold {
+			t.Fatalf("the tail must be fitted again during the refresh")
+		}
+		if *spot.ThresholdInterval != before {
+			t.Errorf("the interval of a stale model must be dropped: %+v", *spot.ThresholdInterval)
+		}
+	})
+}
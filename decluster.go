@@ -0,0 +1,75 @@
+package gospot
+
+import (
+	"fmt"
+	"math"
+)
+
+// addExcess handles the excess ex seen at the n-th step. Without
+// declustering, it is pushed into the tail. Otherwise it is added to the
+// current cluster, the previous one being closed if at least Decluster
+// non-excess steps have been seen since its last excess. It returns true
+// when the tail has changed.
+func (spot *Spot) addExcess(ex float64, n uint64) bool {
+	if spot.Decluster == 0 {
+		spot.Tail.Push(ex)
+		return true
+	}
+	pushed := spot.endCluster(n - 1)
+	if spot.ClusterSize == 0 {
+		spot.Clusters++
+		spot.ClusterMax = ex
+	}
+	spot.ClusterSize++
+	spot.ClusterMax = math.Max(spot.ClusterMax, ex)
+	spot.ClusterEnd = n
+	return pushed
+}
+
+// endCluster closes the current cluster if at least Decluster non-excess
+// steps have been seen up to the n-th step. The maximum of the cluster is
+// pushed into the tail. It returns true when the tail has changed.
+func (spot *Spot) endCluster(n uint64) bool {
+	if spot.ClusterSize == 0 || n < spot.ClusterEnd+spot.Decluster {
+		return false
+	}
+	return spot.flushCluster()
+}
+
+// flushCluster closes the current cluster whatever its age
+func (spot *Spot) flushCluster() bool {
+	if spot.ClusterSize == 0 {
+		return false
+	}
+	spot.Tail.Push(spot.ClusterMax)
+	spot.ClusterSize = 0
+	spot.ClusterMax = 0.0
+	return true
+}
+
+// resetClusters forgets the clusters
+func (spot *Spot) resetClusters() {
+	spot.Clusters = 0
+	spot.ClusterSize = 0
+	spot.ClusterMax = 0.0
+	spot.ClusterEnd = 0
+}
+
+// checkClusters checks the consistency of decoded clusters
+func (spot *Spot) checkClusters() error {
+	if spot.Clusters > spot.Nt || spot.ClusterSize > spot.Nt || spot.ClusterEnd > spot.N {
+		return fmt.Errorf("spot: inconsistent clusters")
+	}
+	return nil
+}
+
+// ExtremalIndex estimates the extremal index of the series with the runs
+// estimator (number of clusters / number of excesses). It is close to 1
+// when the excesses are independent and decreases as they cluster. It
+// returns NaN when declustering is disabled.
+func (spot *Spot) ExtremalIndex() float64 {
+	if spot.Decluster == 0 || spot.Nt == 0 {
+		return math.NaN()
+	}
+	return float64(spot.Clusters) / float64(spot.Nt)
+}
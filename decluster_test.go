@@ -0,0 +1,125 @@
+package gospot
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// movingMax returns a series whose excesses come in clusters of about
+// width values (its extremal index is about 1/width)
+func movingMax(size uint64, width int) []float64 {
+	noise := gaussian(size + uint64(width))
+	out := make([]float64, size)
+	for i := range out {
+		out[i] = noise[i]
+		for _, x := range noise[i : i+width] {
+			out[i] = math.Max(out[i], x)
+		}
+	}
+	return out
+}
+
+func TestDeclusterStep(t *testing.T) {
+	s := defaultSpot()
+	s.Decluster = 3
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+	size := s.Tail.Peaks.Size()
+	nt, clusters := s.Nt, s.Clusters
+	gamma := s.Tail.Gamma
+	et := s.ExcessThreshold
+	normal := et - 1
+
+	// excess, excess, normal, excess (same cluster), then 3 normal values
+	for _, x := range []float64{et + 0.1, et + 0.3, normal, et + 0.2, normal, normal} {
+		s.Step(x)
+	}
+	if s.Nt != nt+3 || s.Clusters != clusters+1 || s.ClusterSize != 3 || math.Abs(s.ClusterMax-0.3) > 1e-9 {
+		t.Errorf("bad cluster: %+v", s)
+	}
+	if s.Tail.Peaks.Size() != size || s.Tail.Gamma != gamma {
+		t.Errorf("the cluster must not be pushed before it ends")
+	}
+	if status := s.Step(normal); status != NORMAL {
+		t.Errorf("bad status: %v", status)
+	}
+	if s.ClusterSize != 0 || s.Tail.Peaks.Size() != min(size+1, 1000) || s.Tail.Peaks.Max < 0.3 {
+		t.Errorf("the maximum of the cluster must be pushed at the end of the cluster")
+	}
+	if s.Tail.Gamma == gamma {
+		t.Errorf("the tail must be fitted at the end of the cluster")
+	}
+}
+
+func TestDeclusterFit(t *testing.T) {
+	s := defaultSpot().WithMaxExcess(5000)
+	s.Decluster = 10
+	if err := s.Fit(movingMax(100_000, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if s.ClusterSize != 0 {
+		t.Errorf("the last cluster must be closed by the fit")
+	}
+	if s.Tail.Peaks.Size() != s.Clusters {
+		t.Errorf("one peak per cluster: %d peaks, %d clusters", s.Tail.Peaks.Size(), s.Clusters)
+	}
+	if theta := s.ExtremalIndex(); theta < 0.1 || theta > 0.35 {
+		t.Errorf("bad extremal index of a clustered series: %v", theta)
+	}
+
+	s.Reset()
+	if s.Clusters != 0 || !math.IsNaN(s.ExtremalIndex()) {
+		t.Errorf("clusters must be reset")
+	}
+	s.Fit(gaussian(100_000))
+	if theta := s.ExtremalIndex(); theta < 0.8 {
+		t.Errorf("bad extremal index of independent data: %v", theta)
+	}
+
+	s.Decluster = 0
+	if !math.IsNaN(s.ExtremalIndex()) {
+		t.Errorf("extremal index must be NaN without declustering")
+	}
+}
+
+func TestDeclusterState(t *testing.T) {
+	s := defaultSpot()
+	s.Decluster = 4
+	s.Fit(gaussian(50_000))
+	s.Step(s.ExcessThreshold + 0.5)
+
+	raw, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBinary := &Spot{}
+	if err := fromBinary.UnmarshalBinary(raw); err != nil {
+		t.Fatal(err)
+	}
+	raw, err = json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON := &Spot{}
+	if err := json.Unmarshal(raw, fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	for _, other := range []*Spot{fromBinary, fromJSON} {
+		if other.Decluster != 4 || other.Clusters != s.Clusters || other.ClusterSize != 1 ||
+			other.ClusterMax != s.ClusterMax || other.ClusterEnd != s.ClusterEnd {
+			t.Errorf("clusters not restored: %+v", other)
+		}
+	}
+
+	s.ClusterEnd = s.N + 1
+	if raw, _ := s.MarshalBinary(); (&Spot{}).UnmarshalBinary(raw) == nil {
+		t.Errorf("inconsistent clusters must be rejected")
+	}
+
+	c := SpotConfig{Q: 1e-4, Level: 0.98, MaxExcess: 100, Decluster: 5}
+	if s, err := c.New(); err != nil || s.Decluster != 5 {
+		t.Errorf("decluster not set from the configuration")
+	}
+}
@@ -0,0 +1,174 @@
+package gospot
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// names of the built-in baselines in the JSON state of [DSpot]
+const (
+	baselineMovingAverage = "moving_average"
+	baselineEWMA          = "ewma"
+)
+
+// DSpot is the drift-aware variant of [Spot]. It runs the SPOT algorithm on
+// the difference between the data and a local [Baseline]. Thresholds are
+// given in the original units (the baseline is added back).
+type DSpot struct {
+	// Detector working on the residuals
+	Spot *Spot `json:"spot"`
+	// Local model of the data
+	Baseline Baseline `json:"baseline"`
+}
+
+// NewDSpot initializes and returns a new DSpot instance whose baseline is
+// the moving average of the last depth values. The other parameters are
+// the same as [NewSpot].
+func NewDSpot(q float64, low bool, discardAnomalies bool, level float64, maxExcess uint64, depth uint64) (*DSpot, error) {
+	baseline, err := NewMovingAverage(depth)
+	if err != nil {
+		return nil, err
+	}
+	spot, err := NewSpot(q, low, discardAnomalies, level, maxExcess)
+	if err != nil {
+		return nil, err
+	}
+	return NewDSpotWithBaseline(spot, baseline), nil
+}
+
+// NewDSpotWithBaseline builds a DSpot instance from a detector and a
+// custom baseline
+func NewDSpotWithBaseline(spot *Spot, baseline Baseline) *DSpot {
+	return &DSpot{
+		Spot:     spot,
+		Baseline: baseline,
+	}
+}
+
+// baselineType returns the name of a built-in baseline ("" otherwise)
+func baselineType(b Baseline) string {
+	switch b.(type) {
+	case *MovingAverage:
+		return baselineMovingAverage
+	case *EWMA:
+		return baselineEWMA
+	}
+	return ""
+}
+
+// MarshalJSON encodes the DSpot instance. The type of a built-in baseline
+// is stored along with its state.
+func (d *DSpot) MarshalJSON() ([]byte, error) {
+	type alias DSpot
+	return json.Marshal(&struct {
+		*alias
+		BaselineType string `json:"baseline_type,omitempty"`
+	}{
+		alias:        (*alias)(d),
+		BaselineType: baselineType(d.Baseline),
+	})
+}
+
+// UnmarshalJSON decodes the DSpot instance. Built-in baselines are
+// created from their type while a custom baseline must be set before
+// decoding.
+func (d *DSpot) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		Spot         *Spot           `json:"spot"`
+		Baseline     json.RawMessage `json:"baseline"`
+		BaselineType string          `json:"baseline_type"`
+	}{}
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+	if aux.Spot == nil {
+		return fmt.Errorf("dspot: missing spot")
+	}
+
+	switch aux.BaselineType {
+	case baselineMovingAverage:
+		d.Baseline = &MovingAverage{}
+	case baselineEWMA:
+		d.Baseline = &EWMA{}
+	case "":
+		if d.Baseline == nil {
+			return fmt.Errorf("dspot: the custom baseline must be set before decoding")
+		}
+	default:
+		return fmt.Errorf("dspot: unknown baseline type %q", aux.BaselineType)
+	}
+	if len(aux.Baseline) == 0 {
+		return fmt.Errorf("dspot: missing baseline")
+	}
+	if err := json.Unmarshal(aux.Baseline, d.Baseline); err != nil {
+		return err
+	}
+	if m, ok := d.Baseline.(*MovingAverage); ok && m.Window == nil {
+		return fmt.Errorf("dspot: missing moving average window")
+	}
+	d.Spot = aux.Spot
+	return nil
+}
+
+// Reset puts the DSpot object into its initial state (before fitting)
+func (d *DSpot) Reset() {
+	d.Spot.Reset()
+	d.Baseline.Reset()
+}
+
+// Fit the DSpot instance against the given values. The first values
+// are only used to initialize the baseline.
+func (d *DSpot) Fit(data []float64) error {
+	d.Baseline.Reset()
+
+	residuals := make([]float64, 0, len(data))
+	for _, x := range data {
+		if d.Baseline.Ready() {
+			residuals = append(residuals, x-d.Baseline.Value())
+		}
+		d.Baseline.Push(x)
+	}
+
+	return d.Spot.Fit(residuals)
+}
+
+// Step updates the DSpot instance with a fresh value x. The returned status
+// has the same meaning as in [Spot.Step]. The anomalies that are discarded
+// by the detector are not pushed to the baseline.
+func (d *DSpot) Step(x float64) SpotStatus {
+	if math.IsNaN(x) {
+		return INTERNAL_ERROR
+	}
+	if !d.Baseline.Ready() {
+		d.Baseline.Push(x)
+		return NORMAL
+	}
+
+	n := d.Spot.N
+	status := d.Spot.Step(x - d.Baseline.Value())
+	if d.Spot.N > n {
+		d.Baseline.Push(x)
+	}
+	return status
+}
+
+// AnomalyThreshold returns the current anomaly threshold in the original units
+func (d *DSpot) AnomalyThreshold() float64 {
+	return d.Baseline.Value() + d.Spot.AnomalyThreshold
+}
+
+// ExcessThreshold returns the current excess threshold in the original units
+func (d *DSpot) ExcessThreshold() float64 {
+	return d.Baseline.Value() + d.Spot.ExcessThreshold
+}
+
+// Quantile computes the value zq such that P(X>zq) = q, given the current baseline
+func (d *DSpot) Quantile(q float64) float64 {
+	return d.Baseline.Value() + d.Spot.Quantile(q)
+}
+
+// Probability computes the probability p such that P(X>z) = p, given the current baseline
+func (d *DSpot) Probability(z float64) float64 {
+	return d.Spot.Probability(z - d.Baseline.Value())
+}
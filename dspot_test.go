@@ -0,0 +1,168 @@
+package gospot
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// trend returns gaussian data with a linear drift
+func trend(start uint64, size uint64, slope float64) []float64 {
+	out := gaussian(size)
+	for i := range out {
+		out[i] += slope * float64(start+uint64(i))
+	}
+	return out
+}
+
+func TestDSpotDrift(t *testing.T) {
+	q := 1e-4
+	level := 0.98
+	maxExcess := uint64(500)
+	trainingSize := uint64(20_000)
+	testSize := uint64(100_000)
+	slope := 1e-3
+
+	s, err := NewSpot(q, false, true, level, maxExcess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDSpot(q, false, true, level, maxExcess, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	training := trend(0, trainingSize, slope)
+	if err := s.Fit(training); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Fit(training); err != nil {
+		t.Fatal(err)
+	}
+
+	spotExcesses := 0
+	dspotExcesses := 0
+	dspotAnomalies := 0
+	for _, x := range trend(trainingSize, testSize, slope) {
+		if s.Step(x) == EXCESS {
+			spotExcesses++
+		}
+		switch d.Step(x) {
+		case EXCESS:
+			dspotExcesses++
+		case ANOMALY:
+			dspotAnomalies++
+		}
+	}
+
+	r := float64(dspotAnomalies) / float64(testSize)
+	if r > 10*q {
+		t.Errorf("Anomaly ratio: %E (A:%d)", r, dspotAnomalies)
+	}
+	// the excess ratio must remain close to 1-level
+	if r := float64(dspotExcesses) / float64(testSize); math.Abs(r-(1-level)) > 0.01 {
+		t.Errorf("Excess ratio: %E (E:%d)", r, dspotExcesses)
+	}
+	if spotExcesses < int(testSize)/2 {
+		t.Errorf("drift must flood spot with excesses: %d", spotExcesses)
+	}
+
+	// thresholds are in original units
+	last := slope * float64(trainingSize+testSize)
+	if d.ExcessThreshold() < last || d.AnomalyThreshold() < d.ExcessThreshold() {
+		t.Errorf("bad thresholds: %v, %v (baseline ~ %v)", d.ExcessThreshold(), d.AnomalyThreshold(), last)
+	}
+	if math.Abs(d.Quantile(q)-d.AnomalyThreshold()) > 1e-3 {
+		t.Errorf("bad quantile: %v != %v", d.Quantile(q), d.AnomalyThreshold())
+	}
+	if p := d.Probability(d.AnomalyThreshold()); p < 0.99*q || p > 1.01*q {
+		t.Errorf("bad probability: %v != %v", p, q)
+	}
+}
+
+func TestDSpotEWMA(t *testing.T) {
+	s, err := NewSpot(1e-4, false, true, 0.98, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := NewEWMA(0.05, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDSpotWithBaseline(s, baseline)
+	if err := d.Fit(trend(0, 20_000, 1e-3)); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the baseline is created from its type
+	other := &DSpot{}
+	if err := json.Unmarshal(raw, other); err != nil {
+		t.Fatal(err)
+	}
+	if other.Baseline.Value() != d.Baseline.Value() {
+		t.Errorf("baseline not restored: %v != %v", other.Baseline.Value(), d.Baseline.Value())
+	}
+	if other.AnomalyThreshold() != d.AnomalyThreshold() {
+		t.Errorf("anomaly threshold not restored: %v != %v", other.AnomalyThreshold(), d.AnomalyThreshold())
+	}
+}
+
+func TestDSpotJSON(t *testing.T) {
+	d, err := NewDSpot(1e-4, false, true, 0.98, 300, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Fit(trend(0, 20_000, 1e-3)); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &DSpot{}
+	if err := json.Unmarshal(raw, other); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := other.Baseline.(*MovingAverage); !ok {
+		t.Fatalf("bad baseline type: %T", other.Baseline)
+	}
+	if other.AnomalyThreshold() != d.AnomalyThreshold() {
+		t.Errorf("anomaly threshold not restored: %v != %v", other.AnomalyThreshold(), d.AnomalyThreshold())
+	}
+	for i := 0; i < 100; i++ {
+		x := float64(i)
+		if a, b := d.Step(x), other.Step(x); a != b {
+			t.Fatalf("restored detector diverges at %d: %v != %v", i, a, b)
+		}
+	}
+
+	// custom baselines must be provided
+	custom := NewDSpotWithBaseline(d.Spot, &constantBaseline{})
+	raw, err = json.Marshal(custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(raw, &DSpot{}); err == nil {
+		t.Errorf("must return an error on an unknown baseline")
+	}
+	if err := json.Unmarshal(raw, &DSpot{Baseline: &constantBaseline{}}); err != nil {
+		t.Error(err)
+	}
+	if err := json.Unmarshal([]byte(`{"spot":{},"baseline":{},"baseline_type":"other"}`), &DSpot{}); err == nil {
+		t.Errorf("must return an error on an unknown baseline type")
+	}
+}
+
+// constantBaseline is a custom baseline
+type constantBaseline struct {
+	C float64 `json:"c"`
+}
+
+func (b *constantBaseline) Push(x float64) {}
+func (b *constantBaseline) Value() float64 { return b.C }
+func (b *constantBaseline) Ready() bool    { return true }
+func (b *constantBaseline) Reset()         {}
@@ -0,0 +1,205 @@
+package gospot
+
+import (
+	"fmt"
+	"math"
+)
+
+// EpisodeRules tell when an episode of anomalies starts and ends
+type EpisodeRules struct {
+	// Number of anomalies within the window that opens an episode
+	Open int `json:"open"`
+	// Number of last steps in which the anomalies are counted
+	Window int `json:"window"`
+	// Number of consecutive normal steps that closes an episode
+	Close int `json:"close"`
+	// Number of steps after the end of an episode during which a new one
+	// cannot start
+	Cooldown int `json:"cooldown"`
+}
+
+// EpisodeEventType is the kind of an [EpisodeEvent]
+type EpisodeEventType int
+
+const (
+	EpisodeStarted EpisodeEventType = iota + 1
+	EpisodeEnded
+)
+
+func (t EpisodeEventType) String() string {
+	switch t {
+	case EpisodeStarted:
+		return "EpisodeStarted"
+	case EpisodeEnded:
+		return "EpisodeEnded"
+	}
+	return fmt.Sprintf("EpisodeEventType(%d)", int(t))
+}
+
+// EpisodeEvent describes an episode when it starts or ends. The steps are
+// counted from the creation (or the last reset) of the tracker.
+type EpisodeEvent struct {
+	Type EpisodeEventType
+	// Step of the first anomaly of the episode
+	Start uint64
+	// Step of the last anomaly of the episode
+	End uint64
+	// Number of steps between the first and the last anomalies (included)
+	Duration uint64
+	// Number of anomalies in the episode
+	Anomalies int
+	// Most extreme value of the episode
+	Peak float64
+	// Lowest tail probability of the episode
+	MinProbability float64
+}
+
+// episodeSample is a step in the window of an [EpisodeTracker]
+type episodeSample struct {
+	anomaly bool
+	x       float64
+	p       float64
+}
+
+// EpisodeTracker groups the anomalies flagged by a [Spot] instance into
+// episodes so that an incident is reported once. A value is an anomaly
+// when it is beyond the anomaly threshold in force.
+type EpisodeTracker struct {
+	// Underlying detector
+	Spot *Spot
+	// Rules of the episodes
+	Rules EpisodeRules
+
+	steps    uint64
+	window   []episodeSample
+	cursor   int
+	count    int
+	open     bool
+	normal   int
+	cooldown int
+	current  EpisodeEvent
+}
+
+// NewEpisodeTracker initializes a tracker on top of the given detector.
+// The rules must satisfy 1 <= open <= window, close >= 1 and cooldown >= 0.
+func NewEpisodeTracker(spot *Spot, rules EpisodeRules) (*EpisodeTracker, error) {
+	if rules.Open < 1 || rules.Window < rules.Open {
+		return nil, fmt.Errorf("episode rules must satisfy 1 <= open <= window")
+	}
+	if rules.Close < 1 {
+		return nil, fmt.Errorf("episode rules must satisfy close >= 1")
+	}
+	if rules.Cooldown < 0 {
+		return nil, fmt.Errorf("episode cooldown must be non-negative")
+	}
+	return &EpisodeTracker{
+		Spot:   spot,
+		Rules:  rules,
+		window: make([]episodeSample, rules.Window),
+	}, nil
+}
+
+// Reset forgets the current episode (the detector is not reset)
+func (t *EpisodeTracker) Reset() {
+	t.steps = 0
+	t.clearWindow()
+	t.open = false
+	t.normal = 0
+	t.cooldown = 0
+	t.current = EpisodeEvent{}
+}
+
+func (t *EpisodeTracker) clearWindow() {
+	for i := range t.window {
+		t.window[i] = episodeSample{}
+	}
+	t.cursor = 0
+	t.count = 0
+}
+
+// Active tells whether an episode is in progress
+func (t *EpisodeTracker) Active() bool {
+	return t.open
+}
+
+// Step updates the detector with a fresh value x (see [Spot.StepDetailed]).
+// It also returns an event when an episode starts or ends (nil otherwise).
+// NaN values are not counted.
+func (t *EpisodeTracker) Step(x float64) (StepResult, *EpisodeEvent) {
+	result := t.Spot.StepDetailed(x)
+	if result.Status == INTERNAL_ERROR {
+		return result, nil
+	}
+	step := t.steps
+	t.steps++
+	anomaly := t.Spot.upDown()*(x-result.AnomalyThreshold) > 0
+
+	if t.open {
+		if anomaly {
+			t.normal = 0
+			t.record(step, x, result.Probability)
+			return result, nil
+		}
+		t.normal++
+		if t.normal < t.Rules.Close {
+			return result, nil
+		}
+		t.open = false
+		t.normal = 0
+		t.cooldown = t.Rules.Cooldown
+		t.clearWindow()
+		event := t.current
+		event.Type = EpisodeEnded
+		return result, &event
+	}
+
+	// slide the window
+	if t.window[t.cursor].anomaly {
+		t.count--
+	}
+	t.window[t.cursor] = episodeSample{anomaly: anomaly, x: x, p: result.Probability}
+	t.cursor = (t.cursor + 1) % len(t.window)
+	if anomaly {
+		t.count++
+	}
+
+	if t.cooldown > 0 {
+		t.cooldown--
+		return result, nil
+	}
+	if t.count < t.Rules.Open {
+		return result, nil
+	}
+
+	// open an episode with the anomalies of the window (oldest first)
+	t.open = true
+	t.current = EpisodeEvent{Peak: math.NaN(), MinProbability: math.NaN()}
+	size := uint64(len(t.window))
+	for i := uint64(0); i < size; i++ {
+		s := t.window[(uint64(t.cursor)+i)%size]
+		if s.anomaly && i+step+1 >= size {
+			t.record(step+1+i-size, s.x, s.p)
+		}
+	}
+	t.clearWindow()
+	event := t.current
+	event.Type = EpisodeStarted
+	return result, &event
+}
+
+// record adds an anomaly to the current episode
+func (t *EpisodeTracker) record(step uint64, x, p float64) {
+	e := &t.current
+	if e.Anomalies == 0 {
+		e.Start = step
+	}
+	e.Anomalies++
+	e.End = step
+	e.Duration = e.End - e.Start + 1
+	if math.IsNaN(e.Peak) || t.Spot.upDown()*(x-e.Peak) > 0 {
+		e.Peak = x
+	}
+	if math.IsNaN(e.MinProbability) || p < e.MinProbability {
+		e.MinProbability = p
+	}
+}
@@ -0,0 +1,141 @@
+package gospot
+
+import (
+	"math"
+	"testing"
+)
+
+func fittedEpisodeTracker(t *testing.T, rules EpisodeRules) *EpisodeTracker {
+	t.Helper()
+	s := defaultSpot()
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+	tracker, err := NewEpisodeTracker(s, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tracker
+}
+
+func TestNewEpisodeTracker(t *testing.T) {
+	for _, rules := range []EpisodeRules{
+		{Open: 0, Window: 1, Close: 1},
+		{Open: 3, Window: 2, Close: 1},
+		{Open: 1, Window: 1, Close: 0},
+		{Open: 1, Window: 1, Close: 1, Cooldown: -1},
+	} {
+		if _, err := NewEpisodeTracker(defaultSpot(), rules); err == nil {
+			t.Errorf("must return an error: %+v", rules)
+		}
+	}
+}
+
+func TestEpisodeTracker(t *testing.T) {
+	tracker := fittedEpisodeTracker(t, EpisodeRules{Open: 2, Window: 3, Close: 3, Cooldown: 5})
+	high := tracker.Spot.AnomalyThreshold + 1
+	normal := tracker.Spot.ExcessThreshold - 1
+
+	// step, value, expected event
+	steps := []struct {
+		x     float64
+		event EpisodeEventType
+	}{
+		{normal, 0},
+		{high, 0}, // 1: isolated anomaly
+		{normal, 0},
+		{normal, 0},
+		{high + 2, 0},          // 4: first anomaly of the episode
+		{normal, 0},            // 5
+		{high, EpisodeStarted}, // 6: 2 anomalies in the last 3 steps
+		{normal, 0},
+		{high + 1, 0}, // 8: last anomaly
+		{normal, 0},
+		{normal, 0},
+		{normal, EpisodeEnded}, // 11: 3 normal steps
+		{high, 0},              // cooldown
+		{high, 0},
+		{high, 0},
+		{high, 0},
+		{high, 0},
+		{high, EpisodeStarted}, // 17: end of the cooldown
+	}
+
+	var started, ended *EpisodeEvent
+	for i, s := range steps {
+		_, event := tracker.Step(s.x)
+		if s.event == 0 {
+			if event != nil {
+				t.Fatalf("step %d: unexpected event %+v", i, event)
+			}
+			continue
+		}
+		if event == nil || event.Type != s.event {
+			t.Fatalf("step %d: expected %v, got %+v", i, s.event, event)
+		}
+		switch i {
+		case 6:
+			started = event
+		case 11:
+			ended = event
+		}
+		// NaN values are ignored
+		if _, event := tracker.Step(math.NaN()); event != nil {
+			t.Fatalf("NaN must not trigger an event")
+		}
+	}
+
+	if started.Start != 4 || started.End != 6 || started.Duration != 3 || started.Anomalies != 2 || started.Peak != high+2 {
+		t.Errorf("bad start event: %+v", started)
+	}
+	if ended.Start != 4 || ended.End != 8 || ended.Duration != 5 || ended.Anomalies != 3 || ended.Peak != high+2 {
+		t.Errorf("bad end event: %+v", ended)
+	}
+	if p := tracker.Spot.Probability(high + 2); math.Abs(ended.MinProbability-p) > 1e-2*p {
+		t.Errorf("bad minimum probability: %v (expected about %v)", ended.MinProbability, p)
+	}
+	if !tracker.Active() {
+		t.Errorf("an episode must be in progress")
+	}
+
+	tracker.Reset()
+	if tracker.Active() {
+		t.Errorf("no episode after reset")
+	}
+	if _, event := tracker.Step(high); event != nil {
+		t.Errorf("a single anomaly must not open an episode")
+	}
+}
+
+func TestEpisodeTrackerLowTail(t *testing.T) {
+	s := defaultSpot().LowerTail()
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+	tracker, _ := NewEpisodeTracker(s, EpisodeRules{Open: 1, Window: 1, Close: 1})
+	low := s.AnomalyThreshold - 1
+
+	_, event := tracker.Step(low)
+	if event == nil || event.Type != EpisodeStarted {
+		t.Fatalf("episode must start")
+	}
+	tracker.Step(low - 1)
+	tracker.Step(low)
+	_, event = tracker.Step(s.ExcessThreshold + 1)
+	if event == nil || event.Type != EpisodeEnded || event.Peak != low-1 || event.Anomalies != 3 {
+		t.Errorf("bad end event: %+v", event)
+	}
+}
+
+func TestEpisodeTrackerNoise(t *testing.T) {
+	tracker := fittedEpisodeTracker(t, EpisodeRules{Open: 3, Window: 10, Close: 20})
+	events := 0
+	for _, x := range gaussian(100_000) {
+		if _, event := tracker.Step(x); event != nil {
+			events++
+		}
+	}
+	if events > 0 {
+		t.Errorf("isolated anomalies must not open episodes (%d events)", events)
+	}
+}
@@ -1,10 +1,90 @@
 package gospot
 
 import (
+	"fmt"
 	"math"
+	"sort"
+	"sync"
 )
 
-type Estimator func() (float64, float64, float64)
+// Estimator computes the parameters of a GPD from the peaks. It returns
+// gamma, sigma and the log-likelihood of the peaks against the fitted
+// distribution.
+type Estimator interface {
+	// Name identifies the estimator in the registry and in the state of a [Tail]
+	Name() string
+	// Estimate fits a GPD to the peaks
+	Estimate(peaks *Peaks) (gamma, sigma, llhood float64)
+}
+
+// estimatorFunc is the [Estimator] built by [NewEstimator]
+type estimatorFunc struct {
+	name string
+	f    func(peaks *Peaks) (float64, float64, float64)
+}
+
+func (e *estimatorFunc) Name() string {
+	return e.name
+}
+
+func (e *estimatorFunc) Estimate(peaks *Peaks) (float64, float64, float64) {
+	return e.f(peaks)
+}
+
+// NewEstimator turns a function into a named [Estimator]
+func NewEstimator(name string, f func(peaks *Peaks) (gamma, sigma, llhood float64)) Estimator {
+	return &estimatorFunc{name: name, f: f}
+}
+
+// DefaultEstimators are the estimators of a [Tail] when none is configured
+var DefaultEstimators = []string{"mom", "grimshaw", "pwm"}
+
+var (
+	estimatorsMu sync.RWMutex
+	estimators   = map[string]Estimator{
+		"mom":      NewEstimator("mom", (*Peaks).MomEstimator),
+		"grimshaw": NewEstimator("grimshaw", (*Peaks).GrimshawEstimator),
+		"pwm":      NewEstimator("pwm", (*Peaks).PWMEstimator),
+		"zhang":    NewEstimator("zhang", (*Peaks).ZhangEstimator),
+	}
+)
+
+// RegisterEstimator makes an estimator available to the tails under its
+// name. It returns an error if the name is empty or already taken.
+func RegisterEstimator(e Estimator) error {
+	name := e.Name()
+	if name == "" {
+		return fmt.Errorf("estimator name must not be empty")
+	}
+	estimatorsMu.Lock()
+	defer estimatorsMu.Unlock()
+	if _, ok := estimators[name]; ok {
+		return fmt.Errorf("estimator %q is already registered", name)
+	}
+	estimators[name] = e
+	return nil
+}
+
+// LookupEstimator returns the registered estimator with the given name
+func LookupEstimator(name string) (Estimator, bool) {
+	estimatorsMu.RLock()
+	defer estimatorsMu.RUnlock()
+	e, ok := estimators[name]
+	return e, ok
+}
+
+// RegisteredEstimators returns the names of the registered estimators
+// in alphabetical order
+func RegisteredEstimators() []string {
+	estimatorsMu.RLock()
+	defer estimatorsMu.RUnlock()
+	names := make([]string, 0, len(estimators))
+	for name := range estimators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
 // MomEstimator computes the 'Method of Moments' estimator for a GPD distribution
 func (peaks *Peaks) MomEstimator() (gamma, sigma float64, llhood float64) {
@@ -23,6 +103,94 @@ func (peaks *Peaks) MomEstimator() (gamma, sigma float64, llhood float64) {
 	return
 }
 
+// PWMEstimator computes the 'Probability Weighted Moments' estimator of
+// Hosking and Wallis for a GPD distribution. Contrary to the method of
+// moments, it does not rely on the variance so it remains usable when
+// gamma >= 0.5 (it requires gamma < 1). It returns NaN values when the
+// peaks are too few or all tied.
+func (peaks *Peaks) PWMEstimator() (gamma, sigma float64, llhood float64) {
+	x := peaks.sortedPeaks()
+	if len(x) < 2 || x[0] == x[len(x)-1] {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+	n := float64(len(x))
+
+	a0 := peaks.Mean()
+	a1 := 0.0
+	for j, xj := range x {
+		// x is sorted in ascending order, j+1 is the rank of xj
+		a1 += (n - float64(j+1)) / (n - 1) * xj
+	}
+	a1 /= n
+
+	d := a0 - 2.0*a1
+	if !(d > 0.0) {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+	k := a0/d - 2.0
+	sigma = 2.0 * a0 * a1 / d
+	gamma = -k
+	llhood = peaks.LogLikelihood(gamma, sigma)
+	return
+}
+
+// ZhangEstimator computes the empirical Bayes estimator of Zhang and
+// Stephens (2009) for a GPD distribution. It averages the profile
+// likelihood over a grid of candidates instead of looking for roots, so it
+// always returns an estimate with few peaks (n < 50), where Grimshaw often
+// ends up on the trivial root (gamma = 0). On heavy tails, the resulting
+// threshold also varies less than with Grimshaw. On light tails, it is the
+// opposite: the trivial root gives a biased but steadier threshold. It is
+// not used by default (see [Tail.SetEstimators]).
+func (peaks *Peaks) ZhangEstimator() (gamma, sigma float64, llhood float64) {
+	x := peaks.sortedPeaks()
+	n := len(x)
+	if n < 2 {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+
+	// k(theta) = -mean(log(1 - theta*x)), it is -gamma
+	k := func(theta float64) float64 {
+		s := 0.0
+		for _, xi := range x {
+			s += math.Log1p(-theta * xi)
+		}
+		return -s / float64(n)
+	}
+
+	m := 20 + int(math.Sqrt(float64(n)))
+	xmax := x[n-1]
+	quartile := x[int(float64(n)/4.0+0.5)-1]
+	if quartile <= 0 {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+
+	thetas := make([]float64, m)
+	profile := make([]float64, m)
+	for j := range thetas {
+		theta := 1.0/xmax + (1.0-math.Sqrt(float64(m)/(float64(j+1)-0.5)))/(3.0*quartile)
+		kj := k(theta)
+		thetas[j] = theta
+		profile[j] = float64(n) * (math.Log(theta/kj) + kj - 1.0)
+	}
+
+	// posterior mean of theta (the weights are normalized in the log space)
+	theta := 0.0
+	for j := range thetas {
+		s := 0.0
+		for i := range thetas {
+			s += math.Exp(profile[i] - profile[j])
+		}
+		theta += thetas[j] / s
+	}
+
+	kHat := k(theta)
+	gamma = -kHat
+	sigma = kHat / theta
+	llhood = peaks.LogLikelihood(gamma, sigma)
+	return
+}
+
 func grimshawW(x float64, extra interface{}) float64 {
 	peaks := extra.(*Peaks)
 	NtLocal := peaks.Size()
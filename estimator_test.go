@@ -0,0 +1,383 @@
+package gospot
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"testing"
+)
+
+var hookEstimators atomic.Int64
+
+// hookEstimator registers an estimator that calls hook before running the
+// Grimshaw one and returns its name. The name is new on every call since
+// the tests may be repeated.
+func hookEstimator(t *testing.T, hook func()) string {
+	t.Helper()
+	name := fmt.Sprintf("test-hook-%d", hookEstimators.Add(1))
+	err := RegisterEstimator(NewEstimator(name, func(peaks *Peaks) (float64, float64, float64) {
+		hook()
+		return peaks.GrimshawEstimator()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestEstimatorRegistry(t *testing.T) {
+	for _, name := range DefaultEstimators {
+		e, ok := LookupEstimator(name)
+		if !ok || e.Name() != name {
+			t.Errorf("estimator %q must be registered", name)
+		}
+	}
+	if _, ok := LookupEstimator("unknown"); ok {
+		t.Errorf("unknown estimator found")
+	}
+
+	if err := RegisterEstimator(NewEstimator("mom", (*Peaks).MomEstimator)); err == nil {
+		t.Errorf("must return an error on a duplicate name")
+	}
+	if err := RegisterEstimator(NewEstimator("", (*Peaks).MomEstimator)); err == nil {
+		t.Errorf("must return an error on an empty name")
+	}
+
+	fixed := NewEstimator("test-fixed", func(peaks *Peaks) (float64, float64, float64) {
+		return 0.1, 1.0, peaks.LogLikelihood(0.1, 1.0)
+	})
+	if _, ok := LookupEstimator("test-fixed"); !ok {
+		if err := RegisterEstimator(fixed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found := false
+	for _, name := range RegisteredEstimators() {
+		found = found || name == "test-fixed"
+	}
+	if !found {
+		t.Errorf("registered estimator not listed: %v", RegisteredEstimators())
+	}
+}
+
+func TestEstimatorInterface(t *testing.T) {
+	peaks := NewPeaks(1000)
+	for _, x := range gaussian(1000) {
+		peaks.Push(math.Abs(x))
+	}
+	g1, s1, l1 := peaks.GrimshawEstimator()
+	e, _ := LookupEstimator("grimshaw")
+	g2, s2, l2 := e.Estimate(peaks)
+	if g1 != g2 || s1 != s2 || l1 != l2 {
+		t.Errorf("registered estimator differs from the method")
+	}
+}
+
+func TestTailSelection(t *testing.T) {
+	// the errors of the next runs (-count) are ignored
+	RegisterEstimator(NewEstimator("test-invalid", func(peaks *Peaks) (float64, float64, float64) {
+		return math.NaN(), -1, math.NaN()
+	}))
+	// an exponential tail with a badly scaled sigma, clearly worse than the
+	// other estimates (the trivial Grimshaw root is the exponential MLE)
+	RegisterEstimator(NewEstimator("test-exp", func(peaks *Peaks) (float64, float64, float64) {
+		return 0.0, 10 * peaks.Mean(), peaks.LogLikelihood(0.0, 10*peaks.Mean())
+	}))
+
+	tail := NewTail(1000)
+	for _, x := range logGaussian(1000) {
+		tail.Push(x)
+	}
+
+	// default: max likelihood among the default estimators
+	tail.Fit()
+	if tail.Winner == "" || tail.Winner == "test-exp" {
+		t.Errorf("bad winner: %q", tail.Winner)
+	}
+	gamma := tail.Gamma
+
+	if err := tail.SetEstimators([]string{"test-exp", "grimshaw"}, SelectMaxLikelihood); err != nil {
+		t.Fatal(err)
+	}
+	exp, _ := LookupEstimator("test-exp")
+	_, _, llExp := exp.Estimate(tail.Peaks)
+	_, _, llGrimshaw := tail.Peaks.GrimshawEstimator()
+	if !(llExp < llGrimshaw) {
+		t.Fatalf("the test estimate must be worse: %v >= %v", llExp, llGrimshaw)
+	}
+	if got := tail.Fit(); got != llGrimshaw || tail.Winner != "grimshaw" {
+		t.Errorf("the most likely estimate must win: %q (%v)", tail.Winner, got)
+	}
+
+	// preference: the first valid estimate wins
+	tail.SetEstimators([]string{"test-invalid", "test-exp", "grimshaw"}, SelectPreference)
+	tail.Fit()
+	if tail.Winner != "test-exp" || tail.Gamma != 0.0 || tail.Sigma != 10*tail.Peaks.Mean() {
+		t.Errorf("bad preferred estimate: %q (%v, %v)", tail.Winner, tail.Gamma, tail.Sigma)
+	}
+	tail.SetEstimators([]string{"test-invalid"}, SelectPreference)
+	if !math.IsNaN(tail.Fit()) || tail.Winner != "" || tail.Gamma != 0.0 {
+		t.Errorf("parameters must be kept when no estimate is valid")
+	}
+
+	if err := tail.SetEstimators([]string{"unknown"}, ""); err == nil {
+		t.Errorf("must return an error on an unknown estimator")
+	}
+	if err := tail.SetEstimators(nil, "best"); err == nil {
+		t.Errorf("must return an error on an unknown selection")
+	}
+	tail.SetEstimators(nil, "")
+	tail.Fit()
+	if tail.Gamma != gamma {
+		t.Errorf("default estimators must be restored")
+	}
+}
+
+func TestTailEstimatorsState(t *testing.T) {
+	s := defaultSpot()
+	if err := s.Tail.SetEstimators([]string{"grimshaw", "mom"}, SelectPreference); err != nil {
+		t.Fatal(err)
+	}
+	s.Fit(gaussian(50_000))
+	if s.Tail.Winner != "grimshaw" {
+		t.Errorf("grimshaw must be preferred: %q", s.Tail.Winner)
+	}
+
+	clone := s.Tail.Clone()
+	clone.Estimators[0] = "mom"
+	if s.Tail.Estimators[0] != "grimshaw" {
+		t.Errorf("the clone must not share the estimators")
+	}
+
+	raw, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBinary := &Spot{}
+	if err := fromBinary.UnmarshalBinary(raw); err != nil {
+		t.Fatal(err)
+	}
+	raw, err = json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON := &Spot{}
+	if err := json.Unmarshal(raw, fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	for _, other := range []*Spot{fromBinary, fromJSON} {
+		tail := other.Tail
+		if tail.Winner != "grimshaw" || tail.Selection != SelectPreference || len(tail.Estimators) != 2 || tail.Estimators[1] != "mom" {
+			t.Errorf("estimators not restored: %+v", tail)
+		}
+	}
+
+	s.Reset()
+	if len(s.Tail.Estimators) != 2 || s.Tail.Selection != SelectPreference {
+		t.Errorf("estimators must be kept by reset")
+	}
+
+	var tail Tail
+	if err := json.Unmarshal([]byte(`{"peaks":{"container":{"capacity":0,"data":[]}},"estimators":["unknown"]}`), &tail); err == nil {
+		t.Errorf("must return an error on an unknown estimator")
+	}
+
+	c := SpotConfig{Q: 1e-4, Level: 0.98, MaxExcess: 100, Estimators: []string{"unknown"}}
+	if _, err := c.New(); err == nil {
+		t.Errorf("must return an error on an unknown estimator")
+	}
+}
+
+func pareto(alpha float64, size uint64) []float64 {
+	out := make([]float64, size)
+	for i := range out {
+		out[i] = math.Pow(1.0-rand.Float64(), -1.0/alpha)
+	}
+	return out
+}
+
+// testEstimator fits the size highest values of data with the given
+// estimator and checks gamma
+func testEstimator(data []float64, size uint64, estimate func(*Peaks) (float64, float64, float64), check func(float64) bool) bool {
+	tail, _ := newTail(data, size)
+	gamma, _, _ := estimate(tail.Peaks)
+	return check(gamma)
+}
+
+func TestPWMAccuracy(t *testing.T) {
+	var size uint64 = 1000
+	N := 30
+	// gamma = 1/alpha, the variance of the peaks is infinite for alpha <= 2
+	// (the estimator is less accurate for heavy tails)
+	cases := map[string]struct {
+		alpha     float64
+		tolerance float64
+		generator func(alpha float64, size uint64) []float64
+	}{
+		"frechet 3":   {3.0, 0.1, frechet},
+		"frechet 1.5": {1.5, 0.15, frechet},
+		"pareto 3":    {3.0, 0.1, pareto},
+		"pareto 1.5":  {1.5, 0.15, pareto},
+	}
+	for name, c := range cases {
+		gamma := 1 / c.alpha
+		check := func(g float64) bool { return math.Abs(g-gamma) < c.tolerance }
+		s := 0
+		for i := 0; i < N; i++ {
+			if testEstimator(c.generator(c.alpha, 100*size), size, (*Peaks).PWMEstimator, check) {
+				s++
+			}
+		}
+		result := float64(s) / float64(N)
+		if result < 0.80 {
+			t.Errorf("%s: success rate: %f%%", name, 100*result)
+		} else {
+			t.Logf("%s: success rate: %f%%", name, 100*result)
+		}
+	}
+}
+
+func TestPWMExponential(t *testing.T) {
+	peaks := NewPeaks(10_000)
+	for i := 0; i < 10_000; i++ {
+		peaks.Push(rand.ExpFloat64() * 2.0)
+	}
+	gamma, sigma, llhood := peaks.PWMEstimator()
+	if math.Abs(gamma) > 0.05 || math.Abs(sigma-2.0) > 0.1 || math.IsNaN(llhood) {
+		t.Errorf("bad estimate: gamma=%v sigma=%v llhood=%v", gamma, sigma, llhood)
+	}
+}
+
+func TestPWMTiedPeaks(t *testing.T) {
+	peaks := NewPeaks(100)
+	peaks.Push(1.5)
+	if gamma, sigma, _ := peaks.PWMEstimator(); !math.IsNaN(gamma) || !math.IsNaN(sigma) {
+		t.Errorf("a single peak must give NaN: gamma=%v sigma=%v", gamma, sigma)
+	}
+	for i := 0; i < 9; i++ {
+		peaks.Push(1.5)
+	}
+	if gamma, sigma, _ := peaks.PWMEstimator(); !math.IsNaN(gamma) || !math.IsNaN(sigma) {
+		t.Errorf("tied peaks must give NaN: gamma=%v sigma=%v", gamma, sigma)
+	}
+
+	// the default selection must not pick the degenerate estimate
+	tail := NewTail(100)
+	for i := 0; i < 10; i++ {
+		tail.Push(1.5)
+	}
+	tail.Fit()
+	if tail.Winner == "pwm" {
+		t.Errorf("pwm must not win on tied peaks: gamma=%v sigma=%v", tail.Gamma, tail.Sigma)
+	}
+}
+
+func TestZhangAccuracy(t *testing.T) {
+	var size uint64 = 1000
+	N := 30
+	cases := map[string]struct {
+		data  func() []float64
+		check func(g float64) bool
+	}{
+		"frechet":  {func() []float64 { return frechet(2.0, 100*size) }, func(g float64) bool { return math.Abs(g-0.5) < 0.1 }},
+		"pareto":   {func() []float64 { return pareto(1.5, 100*size) }, func(g float64) bool { return math.Abs(g-1/1.5) < 0.1 }},
+		"gaussian": {func() []float64 { return gaussian(100 * size) }, func(g float64) bool { return math.Abs(g) < 0.15 }},
+		"uniform":  {func() []float64 { return uniform(100 * size) }, func(g float64) bool { return g < -0.5 }},
+	}
+	for name, c := range cases {
+		s := 0
+		for i := 0; i < N; i++ {
+			if testEstimator(c.data(), size, (*Peaks).ZhangEstimator, c.check) {
+				s++
+			}
+		}
+		result := float64(s) / float64(N)
+		if result < 0.80 {
+			t.Errorf("%s: success rate: %f%%", name, 100*result)
+		} else {
+			t.Logf("%s: success rate: %f%%", name, 100*result)
+		}
+	}
+}
+
+func TestZhangSmallSamples(t *testing.T) {
+	// the anomaly threshold is computed from 30 peaks only, the data are
+	// the same for all the estimators
+	rnd := rand.New(rand.NewSource(42))
+	cases := []struct {
+		name     string
+		value    func() float64
+		quantile float64 // true quantile of probability 1e-4
+		// whether zhang must vary less than grimshaw. On light tails,
+		// grimshaw often falls back to the exponential root whose threshold
+		// is biased but varies less.
+		stabler bool
+	}{
+		{"gaussian", rnd.NormFloat64, 3.719016485455709, false},
+		{"frechet", func() float64 { return math.Pow(-math.Log(rnd.Float64()), -0.5) }, 1 / math.Sqrt(-math.Log1p(-1e-4)), true},
+	}
+	const runs = 200
+
+	for _, c := range cases {
+		stats := func(estimators []string) (median, variance float64, invalid int) {
+			rnd.Seed(42)
+			logs := make([]float64, 0, runs)
+			for i := 0; i < runs; i++ {
+				s, _ := NewSpot(1e-4, false, true, 0.98, 30)
+				s.Tail.SetEstimators(estimators, SelectMaxLikelihood)
+				data := make([]float64, 1500)
+				for j := range data {
+					data[j] = c.value()
+				}
+				s.Fit(data)
+				if math.IsNaN(s.AnomalyThreshold) || math.IsInf(s.AnomalyThreshold, 0) {
+					invalid++
+					continue
+				}
+				logs = append(logs, math.Log(s.AnomalyThreshold))
+			}
+			mean := 0.0
+			for _, v := range logs {
+				mean += v
+			}
+			mean /= float64(len(logs))
+			for _, v := range logs {
+				variance += (v - mean) * (v - mean)
+			}
+			sort.Float64s(logs)
+			return math.Exp(logs[len(logs)/2]), variance / float64(len(logs)), invalid
+		}
+
+		median, variance, invalid := stats([]string{"zhang"})
+		t.Logf("%s [zhang]: median threshold %.3f (true %.3f), variance of the log threshold %.4f",
+			c.name, median, c.quantile, variance)
+		m, v, _ := stats([]string{"grimshaw"})
+		t.Logf("%s [grimshaw]: median threshold %.3f, variance of the log threshold %.4f", c.name, m, v)
+		if c.stabler && variance >= v {
+			t.Errorf("%s: the variance of zhang (%.4f) must be below the one of grimshaw (%.4f)", c.name, variance, v)
+		}
+		if invalid > 0 {
+			t.Errorf("%s: %d invalid thresholds", c.name, invalid)
+		}
+		if math.Abs(math.Log(median/c.quantile)) > 0.15 {
+			t.Errorf("%s: the median threshold %v is too far from %v", c.name, median, c.quantile)
+		}
+	}
+}
+
+func TestZhangDegenerate(t *testing.T) {
+	peaks := NewPeaks(10)
+	peaks.Push(1.0)
+	if g, _, _ := peaks.ZhangEstimator(); !math.IsNaN(g) {
+		t.Errorf("one peak cannot be fitted")
+	}
+	for i := 0; i < 5; i++ {
+		peaks.Push(0.0)
+	}
+	if g, _, _ := peaks.ZhangEstimator(); !math.IsNaN(g) {
+		t.Errorf("null quartile cannot be fitted")
+	}
+}
@@ -0,0 +1,116 @@
+package gospot
+
+import (
+	"math"
+)
+
+// HillPoint is a point of a Hill plot
+type HillPoint struct {
+	// Number of order statistics
+	K int `json:"k"`
+	// Hill estimate of the tail index (gamma)
+	Gamma float64 `json:"gamma"`
+	// Asymptotic standard error of the estimate (gamma/sqrt(k))
+	StdErr float64 `json:"std_err"`
+}
+
+// HillPlot computes the Hill estimates of the tail index on the values
+// u + peaks for every number k of order statistics (from 1 to the number
+// of peaks minus 1). The estimate is only meaningful for heavy tails
+// (gamma > 0). The plot stops as soon as the reference value is not
+// positive.
+func (peaks *Peaks) HillPlot(u float64) []HillPoint {
+	x := peaks.Container.Sorted()
+	n := len(x)
+	if n < 2 {
+		return nil
+	}
+
+	out := make([]HillPoint, 0, n-1)
+	sum := 0.0
+	for k := 1; k < n; k++ {
+		top, ref := u+x[n-k], u+x[n-k-1]
+		if !(ref > 0) {
+			break
+		}
+		sum += math.Log(top)
+		gamma := sum/float64(k) - math.Log(ref)
+		out = append(out, HillPoint{K: k, Gamma: gamma, StdErr: gamma / math.Sqrt(float64(k))})
+	}
+	return out
+}
+
+// Hill computes the Hill estimate of the tail index on the values u + peaks
+// with the k highest values (see [Peaks.HillPlot]). It returns NaN when k
+// is out of range.
+func (peaks *Peaks) Hill(k int, u float64) float64 {
+	if k < 1 || uint64(k) >= peaks.Size() {
+		return math.NaN()
+	}
+	plot := peaks.HillPlot(u)
+	if k > len(plot) {
+		return math.NaN()
+	}
+	return plot[k-1].Gamma
+}
+
+// HillPlot computes the Hill plot of the tail of the detector, on the
+// original values (see [Peaks.HillPlot]). In lower tail mode, the values
+// are negated.
+func (spot *Spot) HillPlot() []HillPoint {
+	return spot.Tail.Peaks.HillPlot(spot.upDown() * spot.ExcessThreshold)
+}
+
+// HillComparison compares the tail index of a fitted tail with the Hill
+// estimate
+type HillComparison struct {
+	// Number of order statistics of the Hill estimate
+	K int `json:"k"`
+	// Hill estimate of the tail index
+	Hill float64 `json:"hill"`
+	// Standard error of the difference between both estimates
+	StdErr float64 `json:"std_err"`
+	// Fitted tail index
+	Gamma float64 `json:"gamma"`
+	// Estimator of the fitted tail index
+	Estimator string `json:"estimator"`
+	// Distance between both estimates in standard errors
+	Z float64 `json:"z"`
+	// Whether the distance is below the tolerated one (false when the
+	// Hill estimate cannot be computed)
+	Consistent bool `json:"consistent"`
+}
+
+// CompareHill checks that the fitted tail index agrees with the Hill
+// estimate using the k highest values (k <= 0 uses all the peaks). Both
+// are consistent when they are less than z standard errors apart, the
+// standard error of the fitted index being the asymptotic one of the
+// maximum likelihood, (1+gamma)/sqrt(n). As the Hill estimator assumes a
+// heavy tail, a light fitted tail (gamma <= 0) that is flagged means that
+// the data look heavy-tailed.
+func (spot *Spot) CompareHill(k int, z float64) HillComparison {
+	plot := spot.HillPlot()
+	if k <= 0 {
+		k = len(plot)
+	}
+	c := HillComparison{
+		K:         k,
+		Hill:      math.NaN(),
+		StdErr:    math.NaN(),
+		Gamma:     spot.Tail.Gamma,
+		Estimator: spot.Tail.Winner,
+		Z:         math.NaN(),
+	}
+	if k < 1 || k > len(plot) {
+		return c
+	}
+	p := plot[k-1]
+	n := float64(spot.Tail.Peaks.Size())
+	c.Hill = p.Gamma
+	c.StdErr = math.Sqrt(p.StdErr*p.StdErr + (1+c.Gamma)*(1+c.Gamma)/n)
+	if c.StdErr > 0 {
+		c.Z = (c.Gamma - c.Hill) / c.StdErr
+		c.Consistent = math.Abs(c.Z) <= z
+	}
+	return c
+}
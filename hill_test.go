@@ -0,0 +1,102 @@
+package gospot
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestHillPlot(t *testing.T) {
+	peaks := NewPeaks(10)
+	for _, x := range []float64{0, 1, 2, 4} {
+		peaks.Push(x)
+	}
+	// values are 1, 2, 3, 5
+	plot := peaks.HillPlot(1)
+	if len(plot) != 3 {
+		t.Fatalf("expected 3 points, got %d", len(plot))
+	}
+	expected := []float64{
+		math.Log(5) - math.Log(3),
+		(math.Log(5)+math.Log(3))/2 - math.Log(2),
+		(math.Log(5)+math.Log(3)+math.Log(2))/3 - math.Log(1),
+	}
+	for i, p := range plot {
+		if p.K != i+1 || math.Abs(p.Gamma-expected[i]) > 1e-12 {
+			t.Errorf("bad point %d: %+v (expected gamma %f)", i, p, expected[i])
+		}
+		if math.Abs(p.StdErr-p.Gamma/math.Sqrt(float64(p.K))) > 1e-12 {
+			t.Errorf("bad standard error: %+v", p)
+		}
+	}
+
+	if g := peaks.Hill(2, 1); g != plot[1].Gamma {
+		t.Errorf("Hill(2) = %f, expected %f", g, plot[1].Gamma)
+	}
+	for _, k := range []int{0, 4} {
+		if !math.IsNaN(peaks.Hill(k, 1)) {
+			t.Errorf("Hill(%d) must be NaN", k)
+		}
+	}
+	// the reference value is not positive
+	if plot := peaks.HillPlot(-1.5); len(plot) != 1 {
+		t.Errorf("the plot must stop at non-positive values, got %d points", len(plot))
+	}
+}
+
+func TestHillPareto(t *testing.T) {
+	alpha := 2.0
+	spot := defaultSpot()
+	if err := spot.Fit(pareto(alpha, 50000)); err != nil {
+		t.Fatal(err)
+	}
+	c := spot.CompareHill(0, 3)
+	t.Logf("%+v", c)
+	if c.K != int(spot.Tail.Peaks.Size())-1 {
+		t.Errorf("all the peaks must be used, got k=%d", c.K)
+	}
+	if math.Abs(c.Hill-1/alpha) > 0.1 {
+		t.Errorf("Hill estimate %f far from %f", c.Hill, 1/alpha)
+	}
+	if !c.Consistent {
+		t.Errorf("the fitted tail must agree with the Hill estimate: %+v", c)
+	}
+
+	// a light tail is inconsistent with Pareto data
+	spot.Tail.Gamma = 0
+	if c := spot.CompareHill(0, 3); c.Consistent {
+		t.Errorf("a disagreement must be flagged: %+v", c)
+	}
+}
+
+func TestHillUnfitted(t *testing.T) {
+	spot := defaultSpot()
+	c := spot.CompareHill(10, 3)
+	if c.Consistent || !math.IsNaN(c.Hill) || !math.IsNaN(c.Z) {
+		t.Errorf("no comparison must be possible: %+v", c)
+	}
+	if plot := spot.HillPlot(); len(plot) != 0 {
+		t.Errorf("no plot before fitting: %v", plot)
+	}
+
+	raw, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := HillComparison{}
+	if err := json.Unmarshal(raw, &other); err != nil {
+		t.Fatal(err)
+	}
+	if other.K != c.K || !math.IsNaN(other.Hill) || !math.IsNaN(other.StdErr) || !math.IsNaN(other.Z) {
+		t.Errorf("comparison not restored: %s", raw)
+	}
+
+	raw, err = json.Marshal(HillPoint{K: 3, Gamma: 0.5, StdErr: math.NaN()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := HillPoint{}
+	if err := json.Unmarshal(raw, &point); err != nil || point.K != 3 || point.Gamma != 0.5 || !math.IsNaN(point.StdErr) {
+		t.Errorf("point not restored: %s (%v)", raw, err)
+	}
+}
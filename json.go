@@ -0,0 +1,322 @@
+package gospot
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// jsonFloat is a float64 that can be encoded in JSON even if it is NaN
+// or infinite. Such values are encoded as the strings "NaN", "+Inf"
+// and "-Inf".
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	x := float64(f)
+	switch {
+	case math.IsNaN(x):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(x, 1):
+		return []byte(`"+Inf"`), nil
+	case math.IsInf(x, -1):
+		return []byte(`"-Inf"`), nil
+	}
+	return json.Marshal(x)
+}
+
+func (f *jsonFloat) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		switch s {
+		case "NaN":
+			*f = jsonFloat(math.NaN())
+		case "+Inf", "Inf":
+			*f = jsonFloat(math.Inf(1))
+		case "-Inf":
+			*f = jsonFloat(math.Inf(-1))
+		default:
+			return fmt.Errorf("invalid float value: %q", s)
+		}
+		return nil
+	}
+	var x float64
+	if err := json.Unmarshal(data, &x); err != nil {
+		return err
+	}
+	*f = jsonFloat(x)
+	return nil
+}
+
+// jsonFloats converts a slice to a slice of [jsonFloat] (nil stays nil)
+func jsonFloats(x []float64) []jsonFloat {
+	if x == nil {
+		return nil
+	}
+	out := make([]jsonFloat, len(x))
+	for i, v := range x {
+		out[i] = jsonFloat(v)
+	}
+	return out
+}
+
+// float64s converts a slice of [jsonFloat] to a slice (nil stays nil)
+func float64s(x []jsonFloat) []float64 {
+	if x == nil {
+		return nil
+	}
+	out := make([]float64, len(x))
+	for i, v := range x {
+		out[i] = float64(v)
+	}
+	return out
+}
+
+// MarshalJSON encodes the container (NaN values are supported)
+func (ubend *Ubend) MarshalJSON() ([]byte, error) {
+	type alias Ubend
+	return json.Marshal(&struct {
+		*alias
+		LastErasedData jsonFloat `json:"last_erased_data"`
+	}{
+		alias:          (*alias)(ubend),
+		LastErasedData: jsonFloat(ubend.LastErasedData),
+	})
+}
+
+// UnmarshalJSON decodes the container and checks its consistency
+func (ubend *Ubend) UnmarshalJSON(data []byte) error {
+	type alias Ubend
+	aux := &struct {
+		*alias
+		LastErasedData jsonFloat `json:"last_erased_data"`
+	}{
+		alias:          (*alias)(ubend),
+		LastErasedData: jsonFloat(math.NaN()),
+	}
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+	ubend.LastErasedData = float64(aux.LastErasedData)
+
+	if uint64(len(ubend.Data)) != ubend.Capacity {
+		return fmt.Errorf("ubend: capacity (%d) does not match data length (%d)", ubend.Capacity, len(ubend.Data))
+	}
+	if ubend.Cursor >= ubend.Capacity && ubend.Cursor != 0 {
+		return fmt.Errorf("ubend: cursor (%d) out of range", ubend.Cursor)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the peaks (NaN values are supported)
+func (peaks *Peaks) MarshalJSON() ([]byte, error) {
+	type alias Peaks
+	return json.Marshal(&struct {
+		*alias
+		Min jsonFloat `json:"min"`
+		Max jsonFloat `json:"max"`
+	}{
+		alias: (*alias)(peaks),
+		Min:   jsonFloat(peaks.Min),
+		Max:   jsonFloat(peaks.Max),
+	})
+}
+
+// UnmarshalJSON decodes the peaks. The minimum and the maximum are
+// computed from the container while the sums are checked against it.
+func (peaks *Peaks) UnmarshalJSON(data []byte) error {
+	type alias Peaks
+	aux := &struct {
+		*alias
+		Min jsonFloat `json:"min"`
+		Max jsonFloat `json:"max"`
+	}{alias: (*alias)(peaks)}
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+	if peaks.Container == nil {
+		return fmt.Errorf("peaks: missing container")
+	}
+	return peaks.restore(peaks.E, peaks.E2)
+}
+
+// MarshalJSON encodes the tail (NaN values are supported)
+func (tail *Tail) MarshalJSON() ([]byte, error) {
+	type alias Tail
+	return json.Marshal(&struct {
+		*alias
+		Gamma jsonFloat `json:"gamma"`
+		Sigma jsonFloat `json:"sigma"`
+	}{
+		alias: (*alias)(tail),
+		Gamma: jsonFloat(tail.Gamma),
+		Sigma: jsonFloat(tail.Sigma),
+	})
+}
+
+// UnmarshalJSON decodes the tail
+func (tail *Tail) UnmarshalJSON(data []byte) error {
+	type alias Tail
+	aux := &struct {
+		*alias
+		Gamma jsonFloat `json:"gamma"`
+		Sigma jsonFloat `json:"sigma"`
+	}{alias: (*alias)(tail)}
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+	tail.Gamma = float64(aux.Gamma)
+	tail.Sigma = float64(aux.Sigma)
+	if tail.Peaks == nil {
+		return fmt.Errorf("tail: missing peaks")
+	}
+	if err := checkEstimators(tail.Estimators, tail.Selection); err != nil {
+		return fmt.Errorf("tail: %v", err)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the interval (NaN bounds are supported)
+func (i Interval) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Lower jsonFloat `json:"lower"`
+		Upper jsonFloat `json:"upper"`
+		Level float64   `json:"level"`
+	}{jsonFloat(i.Lower), jsonFloat(i.Upper), i.Level})
+}
+
+// UnmarshalJSON decodes the interval
+func (i *Interval) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		Lower jsonFloat `json:"lower"`
+		Upper jsonFloat `json:"upper"`
+		Level float64   `json:"level"`
+	}{Lower: jsonFloat(math.NaN()), Upper: jsonFloat(math.NaN())}
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+	i.Lower, i.Upper, i.Level = float64(aux.Lower), float64(aux.Upper), aux.Level
+	return nil
+}
+
+// MarshalJSON encodes the point (NaN values are supported)
+func (p HillPoint) MarshalJSON() ([]byte, error) {
+	type alias HillPoint
+	return json.Marshal(&struct {
+		alias
+		Gamma  jsonFloat `json:"gamma"`
+		StdErr jsonFloat `json:"std_err"`
+	}{
+		alias:  alias(p),
+		Gamma:  jsonFloat(p.Gamma),
+		StdErr: jsonFloat(p.StdErr),
+	})
+}
+
+// UnmarshalJSON decodes the point
+func (p *HillPoint) UnmarshalJSON(data []byte) error {
+	type alias HillPoint
+	aux := &struct {
+		*alias
+		Gamma  jsonFloat `json:"gamma"`
+		StdErr jsonFloat `json:"std_err"`
+	}{alias: (*alias)(p)}
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+	p.Gamma, p.StdErr = float64(aux.Gamma), float64(aux.StdErr)
+	return nil
+}
+
+// MarshalJSON encodes the comparison (NaN values are supported)
+func (c HillComparison) MarshalJSON() ([]byte, error) {
+	type alias HillComparison
+	return json.Marshal(&struct {
+		alias
+		Hill   jsonFloat `json:"hill"`
+		StdErr jsonFloat `json:"std_err"`
+		Gamma  jsonFloat `json:"gamma"`
+		Z      jsonFloat `json:"z"`
+	}{
+		alias:  alias(c),
+		Hill:   jsonFloat(c.Hill),
+		StdErr: jsonFloat(c.StdErr),
+		Gamma:  jsonFloat(c.Gamma),
+		Z:      jsonFloat(c.Z),
+	})
+}
+
+// UnmarshalJSON decodes the comparison
+func (c *HillComparison) UnmarshalJSON(data []byte) error {
+	type alias HillComparison
+	aux := &struct {
+		*alias
+		Hill   jsonFloat `json:"hill"`
+		StdErr jsonFloat `json:"std_err"`
+		Gamma  jsonFloat `json:"gamma"`
+		Z      jsonFloat `json:"z"`
+	}{alias: (*alias)(c)}
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+	c.Hill, c.StdErr = float64(aux.Hill), float64(aux.StdErr)
+	c.Gamma, c.Z = float64(aux.Gamma), float64(aux.Z)
+	return nil
+}
+
+// MarshalJSON encodes the Spot instance. Contrary to the default encoding,
+// it supports the NaN thresholds of an instance that has not been fitted.
+func (spot *Spot) MarshalJSON() ([]byte, error) {
+	type alias Spot
+	return json.Marshal(&struct {
+		*alias
+		AnomalyThreshold jsonFloat   `json:"anomaly_threshold"`
+		ExcessThreshold  jsonFloat   `json:"excess_threshold"`
+		TierThresholds   []jsonFloat `json:"tier_thresholds,omitempty"`
+	}{
+		alias:            (*alias)(spot),
+		AnomalyThreshold: jsonFloat(spot.AnomalyThreshold),
+		ExcessThreshold:  jsonFloat(spot.ExcessThreshold),
+		TierThresholds:   jsonFloats(spot.TierThresholds),
+	})
+}
+
+// UnmarshalJSON decodes the Spot instance and checks its consistency
+func (spot *Spot) UnmarshalJSON(data []byte) error {
+	type alias Spot
+	aux := &struct {
+		*alias
+		AnomalyThreshold jsonFloat   `json:"anomaly_threshold"`
+		ExcessThreshold  jsonFloat   `json:"excess_threshold"`
+		TierThresholds   []jsonFloat `json:"tier_thresholds,omitempty"`
+	}{
+		alias:            (*alias)(spot),
+		AnomalyThreshold: jsonFloat(math.NaN()),
+		ExcessThreshold:  jsonFloat(math.NaN()),
+	}
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+	spot.AnomalyThreshold = float64(aux.AnomalyThreshold)
+	spot.ExcessThreshold = float64(aux.ExcessThreshold)
+	spot.TierThresholds = float64s(aux.TierThresholds)
+
+	if spot.Tail == nil {
+		return fmt.Errorf("spot: missing tail")
+	}
+	if spot.Nt > spot.N {
+		return fmt.Errorf("spot: more excesses (%d) than data (%d)", spot.Nt, spot.N)
+	}
+	if err := spot.checkClusters(); err != nil {
+		return err
+	}
+	if err := spot.checkTracker(); err != nil {
+		return err
+	}
+	if err := spot.checkConfidence(); err != nil {
+		return err
+	}
+	return spot.checkTiers()
+}
@@ -0,0 +1,137 @@
+package gospot
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestJSONFloat(t *testing.T) {
+	for _, x := range []float64{0.0, -1.5, 1e-300, math.NaN(), math.Inf(1), math.Inf(-1)} {
+		raw, err := json.Marshal(jsonFloat(x))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var y jsonFloat
+		if err := json.Unmarshal(raw, &y); err != nil {
+			t.Fatal(err)
+		}
+		if !(float64(y) == x || (math.IsNaN(x) && math.IsNaN(float64(y)))) {
+			t.Errorf("bad decoding of %s: %v != %v", raw, float64(y), x)
+		}
+	}
+
+	var y jsonFloat
+	if err := json.Unmarshal([]byte(`"nope"`), &y); err == nil {
+		t.Errorf("must return an error on invalid strings")
+	}
+}
+
+func TestJSONNotFitted(t *testing.T) {
+	s := defaultSpot()
+	raw, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := &Spot{}
+	if err := json.Unmarshal(raw, other); err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(other.AnomalyThreshold) || !math.IsNaN(other.ExcessThreshold) {
+		t.Errorf("thresholds must be NaN")
+	}
+	if !math.IsNaN(other.Tail.Peaks.Min) || !math.IsNaN(other.Tail.Peaks.Max) {
+		t.Errorf("min/max must be NaN")
+	}
+	if !math.IsNaN(other.Tail.Peaks.Container.LastErasedData) {
+		t.Errorf("last erased data must be NaN")
+	}
+
+	// the restored instance can be trained
+	if err := other.Fit(gaussian(50_000)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	s := defaultSpot()
+	// the container is not filled after the fit
+	if err := s.Fit(gaussian(20_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{0, 10_000, 100_000} {
+		for _, x := range gaussian(uint64(n)) {
+			s.Step(x)
+		}
+
+		raw, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		other := &Spot{}
+		if err := json.Unmarshal(raw, other); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, x := range gaussian(10_000) {
+			if a, b := s.Step(x), other.Step(x); a != b {
+				t.Fatalf("restored instance does not behave like the original: %d != %d", a, b)
+			}
+		}
+		if s.AnomalyThreshold != other.AnomalyThreshold || s.Tail.Gamma != other.Tail.Gamma || s.Tail.Sigma != other.Tail.Sigma {
+			t.Errorf("restored instance has drifted: %v != %v", s.AnomalyThreshold, other.AnomalyThreshold)
+		}
+	}
+}
+
+func TestJSONInvalid(t *testing.T) {
+	s := defaultSpot()
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := string(raw)
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		t.Fatal(err)
+	}
+	peaks := m["tail"].(map[string]interface{})["peaks"].(map[string]interface{})
+	container := peaks["container"].(map[string]interface{})
+
+	cases := map[string]func(){
+		"Nt > n":          func() { m["Nt"] = m["n"].(float64) + 1 },
+		"capacity":        func() { container["capacity"] = container["capacity"].(float64) + 1 },
+		"cursor":          func() { container["cursor"] = container["capacity"] },
+		"sums":            func() { peaks["e"] = peaks["e"].(float64) * 2 },
+		"missing tail":    func() { delete(m, "tail") },
+		"missing peaks":   func() { delete(m["tail"].(map[string]interface{}), "peaks") },
+		"bad float value": func() { m["anomaly_threshold"] = "infinity" },
+	}
+	for name, corrupt := range cases {
+		if err := json.Unmarshal([]byte(state), &m); err != nil {
+			t.Fatal(err)
+		}
+		peaks = m["tail"].(map[string]interface{})["peaks"].(map[string]interface{})
+		container = peaks["container"].(map[string]interface{})
+		corrupt()
+
+		raw, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(raw, &Spot{}); err == nil {
+			t.Errorf("%s: corrupted state must be rejected", name)
+		}
+	}
+
+	if !strings.Contains(state, `"min":`) {
+		t.Errorf("min not encoded")
+	}
+}
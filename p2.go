@@ -6,19 +6,23 @@ import (
 
 // P2 represents the P2 quantile estimator struct
 // See aakinshin.net/posts/p2-quantile-estimator/
+//
+// It can be used in batch (see [P2Quantile]) or on a stream (see
+// [P2.Push] and [P2.Value]).
 type P2 struct {
-	q  []float64 // Array to store quantiles
-	n  []float64 // Array to store indices
-	np []float64 // Array to store adjusted indices
-	dn []float64 // Array to store adjustment factors
+	Q     []float64 `json:"q"`     // Array to store quantiles
+	N     []float64 `json:"n"`     // Array to store indices
+	NP    []float64 `json:"np"`    // Array to store adjusted indices
+	DN    []float64 `json:"dn"`    // Array to store adjustment factors
+	Count uint64    `json:"count"` // Number of pushed values
 }
 
 func NewP2() *P2 {
 	return &P2{
-		q:  make([]float64, 5),
-		n:  make([]float64, 5),
-		np: make([]float64, 5),
-		dn: make([]float64, 5),
+		Q:  make([]float64, 5),
+		N:  make([]float64, 5),
+		NP: make([]float64, 5),
+		DN: make([]float64, 5),
 	}
 }
 
@@ -79,22 +83,23 @@ func sort5(a []float64) {
 // Init initializes the P2 struct with given p value
 func (p2 *P2) Init(p float64) {
 	for i := 0; i < 5; i++ {
-		p2.q[i] = 0.0
-		p2.n[i] = float64(i)
-		p2.np[i] = 0.0
-		p2.dn[i] = 0.0
+		p2.Q[i] = 0.0
+		p2.N[i] = float64(i)
+		p2.NP[i] = 0.0
+		p2.DN[i] = 0.0
 	}
+	p2.Count = 0
 
 	// Set initial values based on p
-	p2.np[1] = 2 * p
-	p2.np[2] = 4 * p
-	p2.np[3] = 2 + 2*p
-	p2.np[4] = 4
-
-	p2.dn[1] = p / 2
-	p2.dn[2] = p
-	p2.dn[3] = (p + 1) / 2
-	p2.dn[4] = 1
+	p2.NP[1] = 2 * p
+	p2.NP[2] = 4 * p
+	p2.NP[3] = 2 + 2*p
+	p2.NP[4] = 4
+
+	p2.DN[1] = p / 2
+	p2.DN[2] = p
+	p2.DN[3] = (p + 1) / 2
+	p2.DN[4] = 1
 }
 
 // sign returns the sign of a float64 value
@@ -111,67 +116,77 @@ func sign(d float64) float64 {
 
 // linear computes the linear interpolation
 func (p2 *P2) linear(i int, d int) float64 {
-	return p2.q[i] + float64(d)*(p2.q[i+d]-p2.q[i])/(p2.n[i+d]-p2.n[i])
+	return p2.Q[i] + float64(d)*(p2.Q[i+d]-p2.Q[i])/(p2.N[i+d]-p2.N[i])
 }
 
 // parabolic computes the parabolic interpolation
 func (p2 *P2) parabolic(i int, d int) float64 {
-	return p2.q[i] + (float64(d)/(p2.n[i+1]-p2.n[i-1]))*((p2.n[i]-p2.n[i-1]+float64(d))*(p2.q[i+1]-p2.q[i])/(p2.n[i+1]-p2.n[i])+(p2.n[i+1]-p2.n[i]-float64(d))*(p2.q[i]-p2.q[i-1])/(p2.n[i]-p2.n[i-1]))
+	return p2.Q[i] + (float64(d)/(p2.N[i+1]-p2.N[i-1]))*((p2.N[i]-p2.N[i-1]+float64(d))*(p2.Q[i+1]-p2.Q[i])/(p2.N[i+1]-p2.N[i])+(p2.N[i+1]-p2.N[i]-float64(d))*(p2.Q[i]-p2.Q[i-1])/(p2.N[i]-p2.N[i-1]))
 }
 
-// quantile computes the P2 quantile
-func (p2 *P2) quantile(x []float64) float64 {
-	size := len(x)
-	if size < 5 {
-		return math.NaN()
-	}
-
-	// Initialize the first 5 elements of q with data
-	for i := 0; i < 5; i++ {
-		p2.q[i] = x[i]
+// Push adds a new value to the estimator (it must be initialized with
+// [P2.Init] first)
+func (p2 *P2) Push(x float64) {
+	p2.Count++
+	if p2.Count <= 5 {
+		// the first 5 values initialize the markers
+		p2.Q[p2.Count-1] = x
+		if p2.Count == 5 {
+			sort5(p2.Q)
+		}
+		return
 	}
 
-	// Sort the first 5 elements
-	sort5(p2.q)
-
-	// Iterate over the remaining elements
-	for j := 5; j < size; j++ {
-		xj := x[j]
-		if xj < p2.q[0] {
-			p2.q[0] = xj
-		} else if xj > p2.q[4] {
-			p2.q[4] = xj
-		} else {
-			k := 0
-			for xj > p2.q[k] {
-				k++
-			}
-			k--
+	if x < p2.Q[0] {
+		p2.Q[0] = x
+	} else if x > p2.Q[4] {
+		p2.Q[4] = x
+	} else {
+		k := 0
+		for x > p2.Q[k] {
+			k++
+		}
+		k--
 
-			// Update indices and adjustment factors
-			for i := k + 1; i < 5; i++ {
-				p2.n[i] += 1.0
-			}
-			for i := 0; i < 5; i++ {
-				p2.np[i] += p2.dn[i]
-			}
+		// Update indices and adjustment factors
+		for i := k + 1; i < 5; i++ {
+			p2.N[i] += 1.0
+		}
+		for i := 0; i < 5; i++ {
+			p2.NP[i] += p2.DN[i]
+		}
 
-			// Update quantile markers
-			for i := 1; i < 4; i++ {
-				d := p2.np[i] - p2.n[i]
-				if (d >= 1 && (p2.n[i+1]-p2.n[i]) > 1) || (d <= -1 && (p2.n[i-1]-p2.n[i]) < -1) {
-					d = sign(d)
-					qp := p2.parabolic(i, int(d))
-					if !(p2.q[i-1] < qp && qp < p2.q[i+1]) {
-						qp = p2.linear(i, int(d))
-					}
-					p2.q[i] = qp
-					p2.n[i] += d
+		// Update quantile markers
+		for i := 1; i < 4; i++ {
+			d := p2.NP[i] - p2.N[i]
+			if (d >= 1 && (p2.N[i+1]-p2.N[i]) > 1) || (d <= -1 && (p2.N[i-1]-p2.N[i]) < -1) {
+				d = sign(d)
+				qp := p2.parabolic(i, int(d))
+				if !(p2.Q[i-1] < qp && qp < p2.Q[i+1]) {
+					qp = p2.linear(i, int(d))
 				}
+				p2.Q[i] = qp
+				p2.N[i] += d
 			}
 		}
 	}
-	return p2.q[2]
+}
+
+// Value returns the current estimate of the quantile (NaN if less than 5
+// values have been pushed)
+func (p2 *P2) Value() float64 {
+	if p2.Count < 5 {
+		return math.NaN()
+	}
+	return p2.Q[2]
+}
+
+// quantile computes the P2 quantile
+func (p2 *P2) quantile(x []float64) float64 {
+	for _, xj := range x {
+		p2.Push(xj)
+	}
+	return p2.Value()
 }
 
 // P2Quantile computes the P2 quantile of the given data with the specified p value
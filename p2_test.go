@@ -115,3 +115,23 @@ func TestErrorCase(t *testing.T) {
 		t.Errorf("output must be NaN, got %v", q)
 	}
 }
+
+func TestP2Stream(t *testing.T) {
+	data := gaussian(10_000)
+	p2 := NewP2()
+	p2.Init(0.98)
+	for i, x := range data {
+		if i < 4 && !math.IsNaN(p2.Value()) {
+			t.Fatalf("value must be NaN with less than 5 values")
+		}
+		p2.Push(x)
+	}
+	if p2.Count != 10_000 || p2.Value() != P2Quantile(0.98, data) {
+		t.Errorf("stream and batch estimates differ: %v != %v", p2.Value(), P2Quantile(0.98, data))
+	}
+
+	p2.Init(0.5)
+	if p2.Count != 0 || !math.IsNaN(p2.Value()) {
+		t.Errorf("estimator not initialized")
+	}
+}
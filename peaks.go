@@ -1,7 +1,9 @@
 package gospot
 
 import (
+	"fmt"
 	"math"
+	"slices"
 )
 
 // Peaks is a stucture that computes stats about the provided excesses
@@ -16,6 +18,9 @@ type Peaks struct {
 	Max float64 `json:"max"`
 	// Underlying data container
 	Container *Ubend `json:"container"`
+	// sorted copy of the container, maintained by Push like the sums
+	// (nil until an estimator needs it)
+	sorted []float64
 }
 
 // NewPeaks initializes a new [Peak] structure
@@ -52,6 +57,51 @@ func (peaks *Peaks) updateStats() uint64 {
 	return maxIteration
 }
 
+// restore recomputes the stats from the container but keeps the given sums
+// (they may slightly differ from a new computation because of rounding).
+// It returns an error if the sums do not match the container.
+func (peaks *Peaks) restore(e, e2 float64) error {
+	peaks.updateStats()
+	peaks.sorted = nil
+	tol := 1e-6 * math.Max(1.0, math.Max(math.Abs(peaks.E), peaks.E2))
+	if math.Abs(e-peaks.E) > tol || math.Abs(e2-peaks.E2) > tol {
+		return fmt.Errorf("peaks: sums do not match the container")
+	}
+	peaks.E, peaks.E2 = e, e2
+	return nil
+}
+
+// Clone returns a deep copy of the peaks
+func (peaks *Peaks) Clone() *Peaks {
+	out := *peaks
+	out.Container = peaks.Container.Clone()
+	out.sorted = slices.Clone(peaks.sorted)
+	return &out
+}
+
+// shift subtracts delta from the peaks (oldest first) and drops the ones
+// that are not positive anymore
+func (peaks *Peaks) shift(delta float64) {
+	data := peaks.Container.Chronological()
+	peaks.Container = NewUbend(peaks.Container.Capacity)
+	for _, x := range data {
+		if x-delta > 0 {
+			peaks.Container.Push(x - delta)
+		}
+	}
+	peaks.updateStats()
+	peaks.sorted = nil
+}
+
+// sortedPeaks returns the peaks in ascending order. The slice is owned by
+// the peaks and must not be modified.
+func (peaks *Peaks) sortedPeaks() []float64 {
+	if peaks.sorted == nil || uint64(len(peaks.sorted)) != peaks.Size() {
+		peaks.sorted = peaks.Container.sortedInto(make([]float64, 0, peaks.Container.Capacity))
+	}
+	return peaks.sorted
+}
+
 // Size returns the current number of peaks
 func (peaks *Peaks) Size() uint64 {
 	return peaks.Container.Size()
@@ -79,6 +129,15 @@ func (peaks *Peaks) Push(x float64) {
 			peaks.updateStats()
 		}
 	}
+
+	if peaks.sorted != nil {
+		if !math.IsNaN(erased) {
+			i, _ := slices.BinarySearch(peaks.sorted, erased)
+			peaks.sorted = slices.Delete(peaks.sorted, i, i+1)
+		}
+		i, _ := slices.BinarySearch(peaks.sorted, x)
+		peaks.sorted = slices.Insert(peaks.sorted, i, x)
+	}
 }
 
 // Mean computes the mean of the peaks
@@ -109,3 +109,43 @@ func TestLikelihoodLogGaussian(t *testing.T) {
 		t.Errorf("bad likelihood: %v < %v or %v < %v", lpos, lneg, lpos, l0)
 	}
 }
+
+func TestPeaksShift(t *testing.T) {
+	peaks := NewPeaks(4)
+	for _, x := range []float64{5, 1, 2, 3, 4} {
+		peaks.Push(x)
+	}
+	// the container holds 1, 2, 3, 4 (oldest first)
+	peaks.shift(1.5)
+	if peaks.Size() != 3 || peaks.Min != 0.5 || peaks.Max != 2.5 || peaks.E != 4.5 {
+		t.Errorf("bad shifted peaks: %+v", peaks)
+	}
+	if got := peaks.Container.Chronological(); got[0] != 0.5 || got[2] != 2.5 {
+		t.Errorf("order not kept: %v", got)
+	}
+
+	peaks.shift(-1)
+	if peaks.Size() != 3 || peaks.Min != 1.5 || peaks.Container.Capacity != 4 {
+		t.Errorf("bad shifted peaks: %+v", peaks)
+	}
+}
+
+func TestSortedPeaks(t *testing.T) {
+	peaks := NewPeaks(50)
+	for i, x := range uniform(500) {
+		peaks.Push(x)
+		if i%7 != 0 {
+			continue
+		}
+		sorted := peaks.sortedPeaks()
+		for j, y := range peaks.Container.Sorted() {
+			if sorted[j] != y {
+				t.Fatalf("the sorted peaks are not maintained after %d values", i+1)
+			}
+		}
+	}
+	peaks.shift(0.5)
+	if uint64(len(peaks.sortedPeaks())) != peaks.Size() {
+		t.Errorf("the sorted peaks must follow a shift")
+	}
+}
@@ -0,0 +1,88 @@
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/asiffer/gospot"
+)
+
+// Anomaly is a sample flagged by a detector
+type Anomaly struct {
+	// Time of the sample
+	Time time.Time
+	// Value of the sample
+	Value float64
+	// Anomaly threshold when the sample has been stepped
+	AnomalyThreshold float64
+	// Probability of the sample when it has been stepped
+	Probability float64
+}
+
+// BackfillResult is the outcome of a detector run over a series
+type BackfillResult struct {
+	// Labels of the series
+	Labels map[string]string
+	// Detector after the run (nil if it could not be fitted)
+	Spot *gospot.Spot
+	// Flagged samples
+	Anomalies []Anomaly
+	// Reason why the series has not been processed (nil on success)
+	Err error
+}
+
+// Backfill runs one detector per series: it is fitted on the first train
+// samples of the series and steps the next ones. The anomalies are the
+// samples beyond the anomaly threshold.
+func Backfill(series []Series, config gospot.SpotConfig, train int) ([]BackfillResult, error) {
+	if _, err := config.New(); err != nil {
+		return nil, err
+	}
+	if train <= 0 {
+		return nil, fmt.Errorf("the number of training samples must be positive")
+	}
+
+	out := make([]BackfillResult, len(series))
+	for i, s := range series {
+		out[i] = backfill(s, config, train)
+	}
+	return out, nil
+}
+
+func backfill(series Series, config gospot.SpotConfig, train int) BackfillResult {
+	result := BackfillResult{Labels: series.Labels}
+	if len(series.Samples) <= train {
+		result.Err = fmt.Errorf("not enough samples (%d) to train the detector", len(series.Samples))
+		return result
+	}
+
+	spot, _ := config.New()
+	data := make([]float64, train)
+	for i, s := range series.Samples[:train] {
+		data[i] = s.Value
+	}
+	if err := spot.Fit(data); err != nil {
+		result.Err = err
+		return result
+	}
+	result.Spot = spot
+
+	upDown := 1.0
+	if spot.Low {
+		upDown = -1.0
+	}
+	for _, s := range series.Samples[train:] {
+		threshold := spot.AnomalyThreshold
+		if !math.IsNaN(s.Value) && upDown*(s.Value-threshold) > 0 {
+			result.Anomalies = append(result.Anomalies, Anomaly{
+				Time:             s.Time,
+				Value:            s.Value,
+				AnomalyThreshold: threshold,
+				Probability:      spot.Probability(s.Value),
+			})
+		}
+		spot.Step(s.Value)
+	}
+	return result
+}
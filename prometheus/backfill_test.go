@@ -0,0 +1,67 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asiffer/gospot"
+)
+
+var config = gospot.SpotConfig{
+	Q:                1e-4,
+	Low:              false,
+	DiscardAnomalies: true,
+	Level:            0.98,
+	MaxExcess:        200,
+}
+
+func samples(start time.Time, values []float64) []Sample {
+	out := make([]Sample, len(values))
+	for i, x := range values {
+		out[i] = Sample{Time: start.Add(time.Duration(i) * time.Minute), Value: x}
+	}
+	return out
+}
+
+func TestBackfill(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0).UTC()
+	values := gaussian(30_000)
+	values[25_000] = 100
+	series := []Series{
+		{Labels: map[string]string{"instance": "a"}, Samples: samples(start, values)},
+		{Labels: map[string]string{"instance": "b"}, Samples: samples(start, gaussian(100))},
+	}
+
+	results, err := Backfill(series, config, 10_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("bad number of results: %d", len(results))
+	}
+
+	a := results[0]
+	if a.Err != nil || a.Spot == nil {
+		t.Fatalf("series must be processed: %v", a.Err)
+	}
+	found := false
+	for _, anomaly := range a.Anomalies {
+		if anomaly.Value == 100 {
+			found = true
+			if !anomaly.Time.Equal(start.Add(25_000*time.Minute)) || anomaly.Probability >= config.Q || anomaly.AnomalyThreshold >= 100 {
+				t.Errorf("bad anomaly: %+v", anomaly)
+			}
+		}
+	}
+	if !found || len(a.Anomalies) > 20 {
+		t.Errorf("bad anomalies: %+v", a.Anomalies)
+	}
+
+	if b := results[1]; b.Err == nil || b.Spot != nil {
+		t.Errorf("short series must not be processed")
+	}
+
+	if _, err := Backfill(series, config, 0); err == nil {
+		t.Errorf("must return an error because train is 0")
+	}
+}
@@ -0,0 +1,129 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sample is a value of a series at a given time
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is a labelled list of samples
+type Series struct {
+	Labels  map[string]string
+	Samples []Sample
+}
+
+// Client queries the Prometheus HTTP API
+type Client struct {
+	// Base URL of the Prometheus server (like http://localhost:9090)
+	URL string
+	// HTTP client used to send the requests (http.DefaultClient if nil)
+	HTTPClient *http.Client
+}
+
+// apiResponse is the envelope of the Prometheus HTTP API responses
+type apiResponse struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+}
+
+type matrixData struct {
+	ResultType string `json:"resultType"`
+	Result     []struct {
+		Metric map[string]string `json:"metric"`
+		Values [][2]interface{}  `json:"values"`
+	} `json:"result"`
+}
+
+// QueryRange evaluates an expression over a range of time (see the
+// /api/v1/query_range endpoint)
+func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]Series, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", formatTime(start))
+	params.Set("end", formatTime(end))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	endpoint := strings.TrimSuffix(c.URL, "/") + "/api/v1/query_range"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var api apiResponse
+	if err := json.Unmarshal(body, &api); err != nil {
+		return nil, fmt.Errorf("bad response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if api.Status != "success" {
+		return nil, fmt.Errorf("query failed (HTTP %d): %s: %s", resp.StatusCode, api.ErrorType, api.Error)
+	}
+
+	var data matrixData
+	if err := json.Unmarshal(api.Data, &data); err != nil {
+		return nil, fmt.Errorf("bad response data: %w", err)
+	}
+	if data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unexpected result type %q", data.ResultType)
+	}
+
+	out := make([]Series, len(data.Result))
+	for i, r := range data.Result {
+		out[i].Labels = r.Metric
+		out[i].Samples = make([]Sample, len(r.Values))
+		for j, v := range r.Values {
+			ts, ok := v[0].(float64)
+			if !ok {
+				return nil, fmt.Errorf("bad timestamp: %v", v[0])
+			}
+			raw, ok := v[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("bad value: %v", v[1])
+			}
+			x, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad value: %w", err)
+			}
+			out[i].Samples[j] = Sample{Time: parseTime(ts), Value: x}
+		}
+	}
+	return out, nil
+}
+
+// formatTime formats a time as a unix timestamp (in seconds)
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 3, 64)
+}
+
+// parseTime converts a unix timestamp (in seconds, millisecond precision) to a time
+func parseTime(ts float64) time.Time {
+	return time.UnixMilli(int64(math.Round(ts * 1000))).UTC()
+}
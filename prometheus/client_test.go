@@ -0,0 +1,91 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// matrixResponse builds a query_range response from series values
+func matrixResponse(start time.Time, step time.Duration, series map[string][]float64) string {
+	results := make([]string, 0, len(series))
+	for name, values := range series {
+		points := make([]string, len(values))
+		for i, x := range values {
+			ts := float64(start.Add(time.Duration(i)*step).UnixMilli()) / 1000
+			points[i] = fmt.Sprintf(`[%.3f,"%v"]`, ts, x)
+		}
+		results = append(results, fmt.Sprintf(`{"metric":{"__name__":"latency","instance":"%s"},"values":[%s]}`, name, strings.Join(points, ",")))
+	}
+	return fmt.Sprintf(`{"status":"success","data":{"resultType":"matrix","result":[%s]}}`, strings.Join(results, ","))
+}
+
+// playback returns a server that answers the canned response and records the query
+func playback(t *testing.T, code int, body string, query *string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query_range" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if query != nil {
+			*query = r.Form.Get("query") + " " + r.Form.Get("start") + " " + r.Form.Get("end") + " " + r.Form.Get("step")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestQueryRange(t *testing.T) {
+	start := time.Unix(1_700_000_000, 500_000_000).UTC()
+	body := matrixResponse(start, time.Minute, map[string][]float64{"a": {1, 2, math.NaN()}})
+	var query string
+	srv := playback(t, http.StatusOK, body, &query)
+
+	c := &Client{URL: srv.URL + "/"}
+	series, err := c.QueryRange(context.Background(), "latency", start, start.Add(2*time.Minute), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "latency 1700000000.500 1700000120.500 60" {
+		t.Errorf("bad query: %s", query)
+	}
+	if len(series) != 1 || series[0].Labels["instance"] != "a" || len(series[0].Samples) != 3 {
+		t.Fatalf("bad series: %+v", series)
+	}
+	s := series[0].Samples
+	if !s[1].Time.Equal(start.Add(time.Minute)) || s[1].Value != 2 || !math.IsNaN(s[2].Value) {
+		t.Errorf("bad samples: %+v", s)
+	}
+}
+
+func TestQueryRangeErrors(t *testing.T) {
+	cases := map[string]struct {
+		code int
+		body string
+	}{
+		"api error":   {http.StatusBadRequest, `{"status":"error","errorType":"bad_data","error":"parse error"}`},
+		"not json":    {http.StatusBadGateway, `<html>`},
+		"vector":      {http.StatusOK, `{"status":"success","data":{"resultType":"vector","result":[]}}`},
+		"bad value":   {http.StatusOK, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[[1,"x"]]}]}}`},
+		"bad pointer": {http.StatusOK, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[["1",1]]}]}}`},
+	}
+	for name, c := range cases {
+		srv := playback(t, c.code, c.body, nil)
+		client := &Client{URL: srv.URL}
+		if _, err := client.QueryRange(context.Background(), "up", time.Now(), time.Now(), time.Minute); err == nil {
+			t.Errorf("%s: must return an error", name)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+// Package prometheus integrates gospot detectors with Prometheus. It
+// exposes the state of the detectors in the Prometheus text format,
+// runs detectors over range queries and exports alerting rules. It does
+// not depend on the Prometheus client libraries.
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/asiffer/gospot"
+)
+
+// DefaultBuckets are the upper bounds (in seconds) of the latency histograms
+var DefaultBuckets = []float64{1e-6, 5e-6, 1e-5, 5e-5, 1e-4, 5e-4, 1e-3, 5e-3, 1e-2, 5e-2, 0.1, 0.5, 1}
+
+var validLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// statuses that are counted
+var statuses = []gospot.SpotStatus{gospot.INTERNAL_ERROR, gospot.NORMAL, gospot.EXCESS, gospot.ANOMALY}
+
+// histogram is a cumulative histogram of durations
+type histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	x := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, b := range h.buckets {
+		if x <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += x
+}
+
+// Detector is a [gospot.SyncSpot] that records the number of statuses it
+// returns and the latency of its steps and fits
+type Detector struct {
+	*gospot.SyncSpot
+	labels  map[string]string
+	counts  [4]atomic.Uint64
+	step    *histogram
+	fit     *histogram
+	encoded string
+}
+
+// NewDetector wraps the given detector. The labels identify it in the
+// exposed metrics. The latency histograms use [DefaultBuckets].
+func NewDetector(spot *gospot.Spot, labels map[string]string) (*Detector, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copied[k] = v
+	}
+	return &Detector{
+		SyncSpot: gospot.NewSyncSpot(spot),
+		labels:   copied,
+		step:     newHistogram(DefaultBuckets),
+		fit:      newHistogram(DefaultBuckets),
+		encoded:  encoded,
+	}, nil
+}
+
+// Labels returns a copy of the labels of the detector
+func (d *Detector) Labels() map[string]string {
+	out := make(map[string]string, len(d.labels))
+	for k, v := range d.labels {
+		out[k] = v
+	}
+	return out
+}
+
+// Step updates the detector with a fresh value x (see [gospot.Spot.Step])
+func (d *Detector) Step(x float64) gospot.SpotStatus {
+	start := time.Now()
+	status := d.SyncSpot.Step(x)
+	d.step.observe(time.Since(start))
+	d.count(status)
+	return status
+}
+
+// StepDetailed updates the detector like [Detector.Step] but also details
+// the outcome (see [gospot.Spot.StepDetailed])
+func (d *Detector) StepDetailed(x float64) gospot.StepResult {
+	start := time.Now()
+	result := d.SyncSpot.StepDetailed(x)
+	d.step.observe(time.Since(start))
+	d.count(result.Status)
+	return result
+}
+
+// count records a status returned by a step
+func (d *Detector) count(status gospot.SpotStatus) {
+	if i := int(status) + 1; i >= 0 && i < len(d.counts) {
+		d.counts[i].Add(1)
+	}
+}
+
+// Fit the detector against the given values (see [gospot.Spot.Fit])
+func (d *Detector) Fit(data []float64) error {
+	start := time.Now()
+	err := d.SyncSpot.Fit(data)
+	d.fit.observe(time.Since(start))
+	return err
+}
+
+// Count returns the number of times the status has been returned by Step
+func (d *Detector) Count(status gospot.SpotStatus) uint64 {
+	if i := int(status) + 1; i >= 0 && i < len(d.counts) {
+		return d.counts[i].Load()
+	}
+	return 0
+}
+
+// escapeLabelValue escapes a label value for the text format
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// encodeLabels returns the labels as name="value" pairs sorted by name
+func encodeLabels(labels map[string]string) (string, error) {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		if !validLabelName.MatchString(name) || strings.HasPrefix(name, "__") {
+			return "", fmt.Errorf("invalid label name %q", name)
+		}
+		if name == "status" || name == "le" {
+			return "", fmt.Errorf("reserved label name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(labels[name]))
+	}
+	return strings.Join(pairs, ","), nil
+}
+
+// formatValue formats a sample value for the text format
+func formatValue(x float64) string {
+	switch {
+	case math.IsNaN(x):
+		return "NaN"
+	case math.IsInf(x, 1):
+		return "+Inf"
+	case math.IsInf(x, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
+
+// Collector exposes the state of a set of detectors in the Prometheus
+// text format. It implements [http.Handler].
+type Collector struct {
+	// Prefix of the metric names
+	Namespace string
+
+	mu        sync.RWMutex
+	detectors []*Detector
+}
+
+// NewCollector initializes an empty collector whose metrics are prefixed
+// by "gospot"
+func NewCollector() *Collector {
+	return &Collector{Namespace: "gospot"}
+}
+
+// Add registers detectors. Two detectors must not have the same labels.
+func (c *Collector) Add(detectors ...*Detector) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range detectors {
+		for _, other := range c.detectors {
+			if other.encoded == d.encoded {
+				return fmt.Errorf("a detector with labels {%s} is already registered", d.encoded)
+			}
+		}
+		c.detectors = append(c.detectors, d)
+	}
+	return nil
+}
+
+// Remove unregisters a detector. It returns false if it was not registered.
+func (c *Collector) Remove(d *Detector) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.detectors {
+		if other == d {
+			c.detectors = append(c.detectors[:i], c.detectors[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// sample is the state of a detector at collection time
+type sample struct {
+	model *gospot.SpotModel
+	nt    uint64
+	n     uint64
+}
+
+// WriteTo writes the metrics of all the detectors in the Prometheus text
+// format (version 0.0.4)
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.RLock()
+	detectors := make([]*Detector, len(c.detectors))
+	copy(detectors, c.detectors)
+	c.mu.RUnlock()
+
+	samples := make([]sample, len(detectors))
+	for i, d := range detectors {
+		d.View(func(spot *gospot.Spot) {
+			samples[i] = sample{model: d.Model(), nt: spot.Nt, n: spot.N}
+		})
+	}
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	name := func(suffix string) string {
+		if c.Namespace == "" {
+			return suffix
+		}
+		return c.Namespace + "_" + suffix
+	}
+	labels := func(d *Detector, extra string) string {
+		all := d.encoded
+		if extra != "" {
+			if all != "" {
+				all += ","
+			}
+			all += extra
+		}
+		if all == "" {
+			return ""
+		}
+		return "{" + all + "}"
+	}
+	gauge := func(metric, help string, value func(s sample) float64) {
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s gauge\n", metric, help, metric)
+		for i, d := range detectors {
+			fmt.Fprintf(cw, "%s%s %s\n", metric, labels(d, ""), formatValue(value(samples[i])))
+		}
+	}
+
+	gauge(name("anomaly_threshold"), "Normal/abnormal threshold.", func(s sample) float64 { return s.model.AnomalyThreshold })
+	gauge(name("excess_threshold"), "Tail threshold.", func(s sample) float64 { return s.model.ExcessThreshold })
+	gauge(name("tail_gamma"), "GPD gamma parameter of the tail.", func(s sample) float64 { return s.model.Gamma })
+	gauge(name("tail_sigma"), "GPD sigma parameter of the tail.", func(s sample) float64 { return s.model.Sigma })
+	gauge(name("excesses"), "Number of excesses (Nt).", func(s sample) float64 { return float64(s.nt) })
+	gauge(name("samples"), "Number of seen data (N).", func(s sample) float64 { return float64(s.n) })
+
+	metric := name("steps_total")
+	fmt.Fprintf(cw, "# HELP %s Number of steps by returned status.\n# TYPE %s counter\n", metric, metric)
+	for _, d := range detectors {
+		for _, status := range statuses {
+			fmt.Fprintf(cw, "%s%s %d\n", metric, labels(d, fmt.Sprintf(`status="%s"`, status)), d.Count(status))
+		}
+	}
+
+	histograms := []struct {
+		metric string
+		help   string
+		get    func(d *Detector) *histogram
+	}{
+		{name("step_duration_seconds"), "Duration of the steps.", func(d *Detector) *histogram { return d.step }},
+		{name("fit_duration_seconds"), "Duration of the fits.", func(d *Detector) *histogram { return d.fit }},
+	}
+	for _, h := range histograms {
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s histogram\n", h.metric, h.help, h.metric)
+		for _, d := range detectors {
+			hist := h.get(d)
+			hist.mu.Lock()
+			for i, b := range hist.buckets {
+				fmt.Fprintf(cw, "%s_bucket%s %d\n", h.metric, labels(d, fmt.Sprintf(`le="%s"`, formatValue(b))), hist.counts[i])
+			}
+			fmt.Fprintf(cw, "%s_bucket%s %d\n", h.metric, labels(d, `le="+Inf"`), hist.count)
+			fmt.Fprintf(cw, "%s_sum%s %s\n", h.metric, labels(d, ""), formatValue(hist.sum))
+			fmt.Fprintf(cw, "%s_count%s %d\n", h.metric, labels(d, ""), hist.count)
+			hist.mu.Unlock()
+		}
+	}
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// ServeHTTP writes the metrics (see [Collector.WriteTo])
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+// countingWriter counts the written bytes and keeps the first error
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
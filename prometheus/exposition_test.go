@@ -0,0 +1,163 @@
+package prometheus
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/asiffer/gospot"
+)
+
+func gaussian(size int) []float64 {
+	out := make([]float64, size)
+	for i := range out {
+		out[i] = rand.NormFloat64()
+	}
+	return out
+}
+
+func newDetector(t *testing.T, labels map[string]string) *Detector {
+	t.Helper()
+	spot, err := gospot.NewSpot(1e-4, false, true, 0.98, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDetector(spot, labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// parseMetrics returns the samples of the text format indexed by name{labels}
+func parseMetrics(t *testing.T, text string) map[string]float64 {
+	t.Helper()
+	out := make(map[string]float64)
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		x, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("bad line %q: %v", line, err)
+		}
+		out[line[:i]] = x
+	}
+	return out
+}
+
+func TestCollector(t *testing.T) {
+	a := newDetector(t, map[string]string{"series": "a", "host": `h"1`})
+	b := newDetector(t, map[string]string{"series": "b"})
+	c := NewCollector()
+	if err := c.Add(a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Fit(gaussian(20_000)); err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range gaussian(10_000) {
+		a.Step(x)
+	}
+	a.Step(1e9)
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("bad content type: %s", rec.Header().Get("Content-Type"))
+	}
+	metrics := parseMetrics(t, rec.Body.String())
+
+	la := `{host="h\"1",series="a"}`
+	model := a.Model()
+	if metrics["gospot_anomaly_threshold"+la] != model.AnomalyThreshold {
+		t.Errorf("bad anomaly threshold: %v", metrics["gospot_anomaly_threshold"+la])
+	}
+	if metrics["gospot_tail_gamma"+la] != model.Gamma || metrics["gospot_tail_sigma"+la] != model.Sigma {
+		t.Errorf("bad tail parameters")
+	}
+	var n uint64
+	a.View(func(spot *gospot.Spot) { n = spot.N })
+	if metrics["gospot_samples"+la] != float64(n) {
+		t.Errorf("bad number of samples: %v != %v", metrics["gospot_samples"+la], n)
+	}
+
+	total := 0.0
+	for _, status := range []string{"NORMAL", "EXCESS", "ANOMALY", "INTERNAL_ERROR"} {
+		total += metrics[`gospot_steps_total{host="h\"1",series="a",status="`+status+`"}`]
+	}
+	if total != 10_001 || metrics[`gospot_steps_total{host="h\"1",series="a",status="ANOMALY"}`] < 1 {
+		t.Errorf("bad step counts: %v", total)
+	}
+	if metrics[`gospot_step_duration_seconds_bucket{host="h\"1",series="a",le="+Inf"}`] != 10_001 {
+		t.Errorf("bad step histogram")
+	}
+	if metrics["gospot_fit_duration_seconds_count"+la] != 1 {
+		t.Errorf("bad fit histogram")
+	}
+
+	// b has not been fitted
+	if text := rec.Body.String(); !strings.Contains(text, `gospot_anomaly_threshold{series="b"} NaN`) {
+		t.Errorf("missing NaN threshold:\n%s", text)
+	}
+
+	if !c.Remove(b) || c.Remove(b) {
+		t.Errorf("bad removal")
+	}
+	var sb strings.Builder
+	if _, err := c.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sb.String(), `series="b"`) {
+		t.Errorf("removed detector must not be exposed")
+	}
+}
+
+func TestDetectorStepDetailed(t *testing.T) {
+	d := newDetector(t, map[string]string{"series": "a"})
+	if err := d.Fit(gaussian(20_000)); err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range gaussian(100) {
+		d.StepDetailed(x)
+	}
+	if result := d.StepDetailed(1e9); result.Status != gospot.ANOMALY {
+		t.Errorf("bad status: %v", result.Status)
+	}
+
+	total := uint64(0)
+	for _, status := range statuses {
+		total += d.Count(status)
+	}
+	if total != 101 || d.Count(gospot.ANOMALY) < 1 {
+		t.Errorf("detailed steps must be counted: %d", total)
+	}
+	if d.step.count != 101 {
+		t.Errorf("detailed steps must be timed: %d", d.step.count)
+	}
+}
+
+func TestCollectorErrors(t *testing.T) {
+	spot, err := gospot.NewSpot(1e-4, false, true, 0.98, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"0abc", "a-b", "__name", "status", "le"} {
+		if _, err := NewDetector(spot, map[string]string{name: "x"}); err == nil {
+			t.Errorf("must return an error on label %q", name)
+		}
+	}
+
+	c := NewCollector()
+	if err := c.Add(newDetector(t, map[string]string{"a": "1"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add(newDetector(t, map[string]string{"a": "1"})); err == nil {
+		t.Errorf("must return an error on duplicated labels")
+	}
+}
@@ -0,0 +1,146 @@
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asiffer/gospot"
+)
+
+var validMetricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Rule is a static alerting rule built from the state of a fitted detector
+type Rule struct {
+	// Name of the monitored metric
+	Metric string
+	// Labels that select the series
+	Labels map[string]string
+	// Fitted detector of the series
+	Spot *gospot.Spot
+}
+
+// Expr returns the PromQL expression of the rule, like
+// metric{job="api"} > 42
+func (r *Rule) Expr() string {
+	op := ">"
+	if r.Spot.Low {
+		op = "<"
+	}
+	return fmt.Sprintf("%s%s %s %s", r.Metric, matchers(r.Labels), op, formatValue(r.Spot.AnomalyThreshold))
+}
+
+// matchers returns the equality matchers of the labels sorted by name.
+// The metric name is not included.
+func matchers(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		if name != "__name__" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(labels[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// RuleGroup is a group of alerting rules in the Prometheus rules file
+// format. It is regenerated from the latest state of the detectors.
+type RuleGroup struct {
+	// Name of the group
+	Name string
+	// Name of the alerts
+	Alert string
+	// How long the threshold must be crossed before firing (0 fires at once)
+	For time.Duration
+
+	rules []Rule
+}
+
+// NewRuleGroup initializes an empty group of rules
+func NewRuleGroup(name, alert string) *RuleGroup {
+	return &RuleGroup{Name: name, Alert: alert}
+}
+
+// Add creates the rule of a series. When metric is empty, the __name__
+// label of the series is used. The detector must be fitted.
+func (g *RuleGroup) Add(metric string, labels map[string]string, spot *gospot.Spot) error {
+	if metric == "" {
+		metric = labels["__name__"]
+	}
+	if !validMetricName.MatchString(metric) {
+		return fmt.Errorf("invalid metric name %q", metric)
+	}
+	for name := range labels {
+		if name != "__name__" && !validLabelName.MatchString(name) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	if spot == nil || math.IsNaN(spot.AnomalyThreshold) || math.IsInf(spot.AnomalyThreshold, 0) {
+		return fmt.Errorf("the detector of %s%s is not fitted", metric, matchers(labels))
+	}
+	g.rules = append(g.rules, Rule{Metric: metric, Labels: labels, Spot: spot})
+	return nil
+}
+
+// Rules returns the rules of the group
+func (g *RuleGroup) Rules() []Rule {
+	return g.rules
+}
+
+// formatDuration formats a duration like Prometheus does (e.g. 5m)
+func formatDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return fmt.Sprintf("%dms", d/time.Millisecond)
+}
+
+// WriteTo writes the group as a Prometheus rules file (YAML)
+func (g *RuleGroup) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	quote := strconv.Quote
+
+	fmt.Fprintf(cw, "groups:\n")
+	fmt.Fprintf(cw, "  - name: %s\n", quote(g.Name))
+	if len(g.rules) == 0 {
+		fmt.Fprintf(cw, "    rules: []\n")
+	} else {
+		fmt.Fprintf(cw, "    rules:\n")
+	}
+	for i := range g.rules {
+		r := &g.rules[i]
+		fmt.Fprintf(cw, "      - alert: %s\n", quote(g.Alert))
+		fmt.Fprintf(cw, "        expr: %s\n", quote(r.Expr()))
+		if g.For > 0 {
+			fmt.Fprintf(cw, "        for: %s\n", formatDuration(g.For))
+		}
+		fmt.Fprintf(cw, "        annotations:\n")
+		fmt.Fprintf(cw, "          q: %s\n", quote(formatValue(r.Spot.Q)))
+		fmt.Fprintf(cw, "          level: %s\n", quote(formatValue(r.Spot.Level)))
+		fmt.Fprintf(cw, "          gamma: %s\n", quote(formatValue(r.Spot.Tail.Gamma)))
+		fmt.Fprintf(cw, "          sigma: %s\n", quote(formatValue(r.Spot.Tail.Sigma)))
+	}
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
@@ -0,0 +1,125 @@
+package prometheus
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asiffer/gospot"
+)
+
+func fittedSpot(t *testing.T, low bool) *gospot.Spot {
+	t.Helper()
+	c := config
+	c.Low = low
+	spot, err := c.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spot.Fit(gaussian(10_000)); err != nil {
+		t.Fatal(err)
+	}
+	return spot
+}
+
+func TestRuleExpr(t *testing.T) {
+	upper := fittedSpot(t, false)
+	r := Rule{Metric: "latency", Labels: map[string]string{"job": "api", "instance": `a"b`, "__name__": "latency"}, Spot: upper}
+	want := `latency{instance="a\"b",job="api"} > ` + formatValue(upper.AnomalyThreshold)
+	if got := r.Expr(); got != want {
+		t.Errorf("bad expression: %s (expected %s)", got, want)
+	}
+
+	lower := fittedSpot(t, true)
+	r = Rule{Metric: "latency", Spot: lower}
+	if got := r.Expr(); got != "latency < "+formatValue(lower.AnomalyThreshold) {
+		t.Errorf("bad lower expression: %s", got)
+	}
+}
+
+func TestRuleGroupAdd(t *testing.T) {
+	g := NewRuleGroup("gospot", "Anomaly")
+	spot := fittedSpot(t, false)
+
+	if err := g.Add("", map[string]string{"__name__": "up", "job": "api"}, spot); err != nil {
+		t.Error(err)
+	}
+	if rules := g.Rules(); len(rules) != 1 || rules[0].Metric != "up" {
+		t.Errorf("bad rules: %+v", rules)
+	}
+
+	fresh, _ := config.New()
+	errors := map[string]func() error{
+		"no metric":     func() error { return g.Add("", map[string]string{"job": "api"}, spot) },
+		"bad metric":    func() error { return g.Add("rate(x[5m])", nil, spot) },
+		"bad label":     func() error { return g.Add("up", map[string]string{"bad-label": "x"}, spot) },
+		"not fitted":    func() error { return g.Add("up", nil, fresh) },
+		"missing model": func() error { return g.Add("up", nil, nil) },
+	}
+	for name, f := range errors {
+		if err := f(); err == nil {
+			t.Errorf("%s: must return an error", name)
+		}
+	}
+	if len(g.Rules()) != 1 {
+		t.Errorf("invalid rules must not be added")
+	}
+}
+
+func TestRuleGroupWriteTo(t *testing.T) {
+	var buf bytes.Buffer
+	g := NewRuleGroup("gospot", "Anomaly")
+	if _, err := g.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "groups:\n  - name: \"gospot\"\n    rules: []\n"; buf.String() != want {
+		t.Errorf("bad empty group:\n%s", buf.String())
+	}
+
+	spot := fittedSpot(t, false)
+	g.For = 5 * time.Minute
+	g.Add("up", map[string]string{"job": "api"}, spot)
+	g.Add("up", map[string]string{"job": "db"}, fittedSpot(t, true))
+	buf.Reset()
+	n, err := g.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("bad number of written bytes: %d (expected %d)", n, buf.Len())
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"    rules:\n      - alert: \"Anomaly\"\n",
+		"        expr: \"up{job=\\\"api\\\"} > " + formatValue(spot.AnomalyThreshold) + "\"\n",
+		"        expr: \"up{job=\\\"db\\\"} < ",
+		"        for: 5m\n",
+		"          q: \"0.0001\"\n",
+		"          level: \"0.98\"\n",
+		"          gamma: \"" + formatValue(spot.Tail.Gamma) + "\"\n",
+		"          sigma: \"" + formatValue(spot.Tail.Sigma) + "\"\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "- alert:") != 2 {
+		t.Errorf("bad number of alerts:\n%s", out)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	cases := map[time.Duration]string{
+		2 * time.Hour:           "2h",
+		90 * time.Minute:        "90m",
+		30 * time.Second:        "30s",
+		1500 * time.Millisecond: "1500ms",
+	}
+	for d, want := range cases {
+		if got := formatDuration(d); got != want {
+			t.Errorf("bad format of %v: %s (expected %s)", d, got, want)
+		}
+	}
+}
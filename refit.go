@@ -0,0 +1,98 @@
+package gospot
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// timeNow is the clock used by the refit policies
+var timeNow = time.Now
+
+// RefitPolicy decides whether the tail must be fitted again when a new
+// excess is pushed. The criteria are combined: the tail is fitted as soon
+// as one of them is met. When they are all disabled, the tail is fitted on
+// every excess. Between two fits, the last GPD parameters and anomaly
+// threshold are kept.
+type RefitPolicy struct {
+	// Fit every k excesses (0 disables the criterion)
+	Every uint64 `json:"every"`
+	// Fit when the mean or the variance of the peaks has moved by more than
+	// this relative tolerance since the last fit (0 disables the criterion)
+	Tolerance float64 `json:"tolerance"`
+	// Fit when this duration has elapsed since the last fit (0 disables the criterion)
+	Interval time.Duration `json:"interval"`
+	// Number of excesses pushed since the last fit
+	Pending uint64 `json:"pending"`
+	// Mean of the peaks at the last fit
+	LastMean float64 `json:"last_mean"`
+	// Variance of the peaks at the last fit
+	LastVar float64 `json:"last_var"`
+	// Time of the last fit
+	LastFit time.Time `json:"last_fit"`
+}
+
+// NewRefitPolicy initializes a policy that fits the tail every k excesses
+func NewRefitPolicy(every uint64) *RefitPolicy {
+	return &RefitPolicy{Every: every}
+}
+
+// check checks the criteria of the policy
+func (p *RefitPolicy) check() error {
+	if !(p.Tolerance >= 0) {
+		return fmt.Errorf("refit tolerance must be positive")
+	}
+	if p.Interval < 0 {
+		return fmt.Errorf("refit interval must be positive")
+	}
+	return nil
+}
+
+// Reset forgets the last fit
+func (p *RefitPolicy) Reset() {
+	p.Pending = 0
+	p.LastMean = 0.0
+	p.LastVar = 0.0
+	p.LastFit = time.Time{}
+}
+
+func moved(current, last, tolerance float64) bool {
+	return math.Abs(current-last) > tolerance*math.Abs(last)
+}
+
+// due tells whether the tail must be fitted given that a new excess
+// has just been pushed into peaks
+func (p *RefitPolicy) due(peaks *Peaks) bool {
+	p.Pending++
+
+	enabled := false
+	if p.Every > 0 {
+		enabled = true
+		if p.Pending >= p.Every {
+			return true
+		}
+	}
+	if p.Tolerance > 0 {
+		enabled = true
+		if moved(peaks.Mean(), p.LastMean, p.Tolerance) || moved(peaks.Var(), p.LastVar, p.Tolerance) {
+			return true
+		}
+	}
+	if p.Interval > 0 {
+		enabled = true
+		if timeNow().Sub(p.LastFit) >= p.Interval {
+			return true
+		}
+	}
+	return !enabled
+}
+
+// fitted records a fit of the tail
+func (p *RefitPolicy) fitted(peaks *Peaks) {
+	p.Pending = 0
+	if peaks.Size() > 0 {
+		p.LastMean = peaks.Mean()
+		p.LastVar = peaks.Var()
+	}
+	p.LastFit = timeNow()
+}
@@ -0,0 +1,138 @@
+package gospot
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// countFits steps the detector and returns the number of excesses and of tail fits
+func countFits(s *Spot, data []float64) (excesses int, fits int) {
+	gamma, sigma := s.Tail.Gamma, s.Tail.Sigma
+	for _, x := range data {
+		if s.Step(x) == EXCESS {
+			excesses++
+		}
+		if s.Tail.Gamma != gamma || s.Tail.Sigma != sigma {
+			fits++
+			gamma, sigma = s.Tail.Gamma, s.Tail.Sigma
+		}
+	}
+	return
+}
+
+func TestRefitEvery(t *testing.T) {
+	s := defaultSpot()
+	s.Refit = NewRefitPolicy(10)
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+	if s.Refit.Pending != 0 || s.Refit.LastFit.IsZero() {
+		t.Errorf("fit not recorded by the policy")
+	}
+
+	excesses, fits := countFits(s, gaussian(50_000))
+	if fits > excesses/10 || fits < excesses/10-1 {
+		t.Errorf("bad number of fits: %d (excesses: %d)", fits, excesses)
+	}
+	if s.Refit.Pending != uint64(excesses%10) {
+		t.Errorf("bad pending excesses: %d", s.Refit.Pending)
+	}
+}
+
+func TestRefitTolerance(t *testing.T) {
+	s := defaultSpot()
+	s.Refit = &RefitPolicy{Tolerance: 0.01}
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	excesses, fits := countFits(s, gaussian(50_000))
+	if fits == 0 || fits >= excesses/2 {
+		t.Errorf("bad number of fits: %d (excesses: %d)", fits, excesses)
+	}
+}
+
+func TestRefitInterval(t *testing.T) {
+	clock := time.Unix(0, 0)
+	timeNow = func() time.Time { return clock }
+	defer func() { timeNow = time.Now }()
+
+	s := defaultSpot()
+	s.Refit = &RefitPolicy{Interval: time.Minute}
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, fits := countFits(s, gaussian(10_000)); fits != 0 {
+		t.Errorf("tail must not be fitted before the interval: %d fits", fits)
+	}
+	clock = clock.Add(time.Minute)
+	if _, fits := countFits(s, gaussian(10_000)); fits != 1 {
+		t.Errorf("tail must be fitted once after the interval: %d fits", fits)
+	}
+}
+
+func TestRefitDefault(t *testing.T) {
+	s := defaultSpot()
+	s.Refit = &RefitPolicy{}
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+	excesses, fits := countFits(s, gaussian(10_000))
+	if fits != excesses {
+		t.Errorf("tail must be fitted on every excess: %d != %d", fits, excesses)
+	}
+}
+
+func TestRefitJSON(t *testing.T) {
+	s := defaultSpot()
+	s.Refit = NewRefitPolicy(5)
+	if err := s.Fit(gaussian(100_000)); err != nil {
+		t.Fatal(err)
+	}
+	countFits(s, gaussian(1_000))
+
+	raw, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &Spot{}
+	if err := json.Unmarshal(raw, other); err != nil {
+		t.Fatal(err)
+	}
+	if other.Refit == nil || !other.Refit.LastFit.Equal(s.Refit.LastFit) {
+		t.Fatalf("refit policy not restored: %+v != %+v", other.Refit, s.Refit)
+	}
+	other.Refit.LastFit = s.Refit.LastFit
+	if *other.Refit != *s.Refit {
+		t.Errorf("refit policy not restored: %+v != %+v", other.Refit, s.Refit)
+	}
+
+	s.Reset()
+	if s.Refit.Pending != 0 || !s.Refit.LastFit.IsZero() {
+		t.Errorf("refit policy not reset")
+	}
+}
+
+func TestRefitConfig(t *testing.T) {
+	policy := &RefitPolicy{Every: 5, Tolerance: 0.1, Interval: time.Minute, Pending: 3}
+	c := SpotConfig{Q: 1e-3, Level: 0.98, MaxExcess: 100, Refit: policy}
+	s, err := c.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Refit == nil || s.Refit == policy {
+		t.Fatalf("the refit policy must be copied")
+	}
+	if s.Refit.Every != 5 || s.Refit.Tolerance != 0.1 || s.Refit.Interval != time.Minute || s.Refit.Pending != 0 {
+		t.Errorf("bad refit policy: %+v", s.Refit)
+	}
+
+	for _, p := range []RefitPolicy{{Tolerance: -0.1}, {Interval: -time.Second}} {
+		c.Refit = &p
+		if _, err := c.New(); err == nil {
+			t.Errorf("must return an error on %+v", p)
+		}
+	}
+}
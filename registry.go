@@ -0,0 +1,201 @@
+package gospot
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Registry manages one [Spot] detector per series. Detectors are created
+// from a shared configuration the first time a series is seen and fitted
+// once enough values have been buffered.
+//
+// The number of series can be bounded: the least recently stepped series
+// are evicted when MaxSeries is reached, or once they have been idle for
+// IdleTTL. All the methods are safe for concurrent use: the series are
+// locked one by one so that a fit does not block the other series.
+type Registry struct {
+	// Template of the detectors
+	Config SpotConfig
+	// Number of values buffered before fitting a new detector
+	FitSize int
+	// Maximum number of series (0 means no limit)
+	MaxSeries int
+	// Series that have not been stepped for this duration are evicted (0 means never)
+	IdleTTL time.Duration
+
+	mu sync.Mutex
+	// series id -> element of lru
+	entries map[string]*list.Element
+	// most recently used series at the front
+	lru *list.List
+	now func() time.Time
+}
+
+type registryEntry struct {
+	id       string
+	lastSeen time.Time
+
+	// serializes the steps of the series, the fields below are guarded
+	mu     sync.Mutex
+	spot   *Spot
+	buffer []float64
+}
+
+// NewRegistry initializes a new registry whose detectors are built from
+// config and fitted on their first fitSize values
+func NewRegistry(config SpotConfig, fitSize int) (*Registry, error) {
+	if _, err := config.New(); err != nil {
+		return nil, err
+	}
+	if fitSize <= 0 {
+		return nil, fmt.Errorf("fit size must be positive")
+	}
+	return &Registry{
+		Config:  config,
+		FitSize: fitSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}, nil
+}
+
+// Step routes x to the detector of the series id (creating it if needed).
+// The boolean is false while the detector is buffering its training data,
+// the status is then [NORMAL]. If the fit fails, the buffer is dropped and
+// [INTERNAL_ERROR] is returned.
+func (r *Registry) Step(id string, x float64) (SpotStatus, bool) {
+	r.mu.Lock()
+	now := r.now()
+	r.evictIdle(now)
+	entry, err := r.get(id, now)
+	r.mu.Unlock()
+	if err != nil {
+		return INTERNAL_ERROR, false
+	}
+
+	// the series may be evicted meanwhile, the value is then lost with it
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.buffer != nil {
+		entry.buffer = append(entry.buffer, x)
+		if len(entry.buffer) < r.FitSize {
+			return NORMAL, false
+		}
+		data := entry.buffer
+		entry.buffer = nil
+		if err := entry.spot.Fit(data); err != nil {
+			entry.spot.Reset()
+			entry.buffer = make([]float64, 0, r.FitSize)
+			return INTERNAL_ERROR, false
+		}
+		return NORMAL, true
+	}
+
+	return entry.spot.Step(x), true
+}
+
+// get returns the entry of the series id, creating it if it does not exist.
+// It must be called with the lock held.
+func (r *Registry) get(id string, now time.Time) (*registryEntry, error) {
+	if elem, ok := r.entries[id]; ok {
+		r.lru.MoveToFront(elem)
+		entry := elem.Value.(*registryEntry)
+		entry.lastSeen = now
+		return entry, nil
+	}
+
+	spot, err := r.Config.New()
+	if err != nil {
+		return nil, err
+	}
+	if r.MaxSeries > 0 {
+		for r.lru.Len() >= r.MaxSeries {
+			r.remove(r.lru.Back())
+		}
+	}
+	entry := &registryEntry{
+		id:       id,
+		spot:     spot,
+		buffer:   make([]float64, 0, r.FitSize),
+		lastSeen: now,
+	}
+	r.entries[id] = r.lru.PushFront(entry)
+	return entry, nil
+}
+
+// remove drops a series. It must be called with the lock held.
+func (r *Registry) remove(elem *list.Element) {
+	entry := r.lru.Remove(elem).(*registryEntry)
+	delete(r.entries, entry.id)
+}
+
+// evictIdle drops the series that have been idle for too long. It must be
+// called with the lock held.
+func (r *Registry) evictIdle(now time.Time) int {
+	if r.IdleTTL <= 0 {
+		return 0
+	}
+	evicted := 0
+	for elem := r.lru.Back(); elem != nil; elem = r.lru.Back() {
+		if now.Sub(elem.Value.(*registryEntry).lastSeen) < r.IdleTTL {
+			break
+		}
+		r.remove(elem)
+		evicted++
+	}
+	return evicted
+}
+
+// Evict drops the idle series and returns how many were removed
+func (r *Registry) Evict() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.evictIdle(r.now())
+}
+
+// Get returns the detector of the series id (nil if it does not exist).
+// The second value tells whether the detector has been fitted. The returned
+// detector must not be used concurrently with the registry.
+func (r *Registry) Get(id string) (*Spot, bool) {
+	r.mu.Lock()
+	elem, ok := r.entries[id]
+	r.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*registryEntry)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	return entry.spot, entry.buffer == nil
+}
+
+// Remove drops the series id. It returns false if the series does not exist.
+func (r *Registry) Remove(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	elem, ok := r.entries[id]
+	if ok {
+		r.remove(elem)
+	}
+	return ok
+}
+
+// Len returns the number of series
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lru.Len()
+}
+
+// IDs returns the series ids, the most recently stepped first
+func (r *Registry) IDs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, r.lru.Len())
+	for elem := r.lru.Front(); elem != nil; elem = elem.Next() {
+		ids = append(ids, elem.Value.(*registryEntry).id)
+	}
+	return ids
+}
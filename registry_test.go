@@ -0,0 +1,190 @@
+package gospot
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func defaultConfig() SpotConfig {
+	return SpotConfig{
+		Q:                1e-4,
+		Low:              false,
+		DiscardAnomalies: true,
+		Level:            0.98,
+		MaxExcess:        200,
+	}
+}
+
+func TestRegistryFit(t *testing.T) {
+	fitSize := 5000
+	r, err := NewRegistry(defaultConfig(), fitSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := gaussian(uint64(fitSize))
+	for i, x := range data[:fitSize-1] {
+		status, ready := r.Step("a", x)
+		if ready || status != NORMAL {
+			t.Fatalf("detector must be buffering at step %d", i)
+		}
+	}
+	if _, ready := r.Step("a", data[fitSize-1]); !ready {
+		t.Fatalf("detector must be fitted")
+	}
+
+	spot, ready := r.Get("a")
+	if !ready || spot.N != uint64(fitSize) {
+		t.Fatalf("detector not fitted on the buffered data")
+	}
+	if status, _ := r.Step("a", spot.AnomalyThreshold+1); status != ANOMALY {
+		t.Errorf("bad status: %d instead of %d", status, ANOMALY)
+	}
+
+	// series are independent
+	if _, ready := r.Step("b", 0.0); ready {
+		t.Errorf("new series must be buffering")
+	}
+	if r.Len() != 2 {
+		t.Errorf("bad number of series: %d", r.Len())
+	}
+}
+
+func TestRegistryFitError(t *testing.T) {
+	r, err := NewRegistry(defaultConfig(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 9; i++ {
+		r.Step("a", 1.0)
+	}
+	// constant data cannot be fitted
+	if status, ready := r.Step("a", 1.0); ready || status != INTERNAL_ERROR {
+		t.Errorf("fit must fail on constant data")
+	}
+	if _, ready := r.Step("a", 1.0); ready {
+		t.Errorf("detector must buffer again")
+	}
+}
+
+func TestRegistryMaxSeries(t *testing.T) {
+	r, err := NewRegistry(defaultConfig(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.MaxSeries = 3
+
+	for i := 0; i < 3; i++ {
+		r.Step(fmt.Sprint(i), 0.0)
+	}
+	// "0" becomes the most recent series
+	r.Step("0", 0.0)
+	r.Step("3", 0.0)
+
+	if r.Len() != 3 {
+		t.Errorf("bad number of series: %d", r.Len())
+	}
+	if spot, _ := r.Get("1"); spot != nil {
+		t.Errorf("least recently used series must be evicted")
+	}
+	ids := r.IDs()
+	for i, id := range []string{"3", "0", "2"} {
+		if ids[i] != id {
+			t.Errorf("bad order: %v", ids)
+		}
+	}
+}
+
+func TestRegistryIdleTTL(t *testing.T) {
+	r, err := NewRegistry(defaultConfig(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.IdleTTL = time.Minute
+
+	clock := time.Unix(0, 0)
+	r.now = func() time.Time { return clock }
+
+	r.Step("a", 0.0)
+	clock = clock.Add(30 * time.Second)
+	r.Step("b", 0.0)
+	clock = clock.Add(45 * time.Second)
+
+	if n := r.Evict(); n != 1 {
+		t.Errorf("bad number of evicted series: %d", n)
+	}
+	if spot, _ := r.Get("a"); spot != nil {
+		t.Errorf("idle series must be evicted")
+	}
+
+	clock = clock.Add(time.Minute)
+	r.Step("c", 0.0)
+	if r.Len() != 1 {
+		t.Errorf("idle series must be evicted on step: %v", r.IDs())
+	}
+	if !r.Remove("c") || r.Remove("c") {
+		t.Errorf("bad removal")
+	}
+}
+
+func TestBadRegistry(t *testing.T) {
+	config := defaultConfig()
+	if _, err := NewRegistry(config, 0); err == nil {
+		t.Errorf("must return an error because fit size is 0")
+	}
+	config.Level = 1.0
+	if _, err := NewRegistry(config, 10); err == nil {
+		t.Errorf("must return an error because level >= 1")
+	}
+}
+
+func TestRegistryConcurrentFit(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	estimator := hookEstimator(t, func() {
+		once.Do(func() {
+			close(started)
+			<-release
+		})
+	})
+	config := defaultConfig()
+	config.Estimators = []string{estimator}
+	fitSize := 2000
+	r, err := NewRegistry(config, fitSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := gaussian(uint64(fitSize))
+	for _, x := range data[:fitSize-1] {
+		r.Step("a", x)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Step("a", data[fitSize-1])
+	}()
+	<-started
+
+	// the other series are not blocked by the fit of "a"
+	other := make(chan struct{})
+	go func() {
+		defer close(other)
+		r.Step("b", 0.0)
+		r.Get("b")
+		r.Len()
+	}()
+	select {
+	case <-other:
+	case <-time.After(5 * time.Second):
+		t.Errorf("the fit of a series must not block the other ones")
+	}
+	close(release)
+	<-done
+	<-other
+	if _, ready := r.Get("a"); !ready {
+		t.Errorf("detector must be fitted")
+	}
+}
@@ -0,0 +1,444 @@
+// Package server exposes gospot detectors over a REST API.
+//
+// The detectors are identified by a name made of letters, digits, '.',
+// '_' and '-'. The routes are:
+//
+//	GET    /detectors                   list the detectors
+//	POST   /detectors/{id}              create a detector (body: gospot.SpotConfig)
+//	GET    /detectors/{id}              download the state of a detector
+//	PUT    /detectors/{id}              upload the state of a detector
+//	DELETE /detectors/{id}              delete a detector
+//	POST   /detectors/{id}/fit          fit a detector (body: {"data": [...]})
+//	POST   /detectors/{id}/step         step a value ({"value": x}) or a batch ({"values": [...]})
+//	GET    /detectors/{id}/model        current thresholds and GPD parameters
+//	GET    /detectors/{id}/quantile     quantile of the probability given by the 'q' parameter
+//	GET    /detectors/{id}/probability  probability of the value given by the 'z' parameter
+//
+// When a directory is given, the state of every detector is saved after
+// each modification and loaded back when the server starts.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/asiffer/gospot"
+)
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// errNotFound is returned when a detector does not exist
+var errNotFound = errors.New("detector not found")
+
+// Server handles the detectors and their HTTP API
+type Server struct {
+	// directory where the states are saved (empty means no persistence)
+	dir       string
+	mu        sync.RWMutex
+	detectors map[string]*gospot.SyncSpot
+	// serializes the writes to the directory
+	saveMu sync.Mutex
+}
+
+// New creates a server whose detectors are saved into dir. The detectors
+// already saved in dir are loaded. If dir is empty, the detectors are
+// only kept in memory.
+func New(dir string) (*Server, error) {
+	s := &Server{
+		dir:       dir,
+		detectors: make(map[string]*gospot.SyncSpot),
+	}
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".json")
+		if !validID.MatchString(id) {
+			continue
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		detector := &gospot.SyncSpot{}
+		if err := json.Unmarshal(raw, detector); err != nil {
+			return nil, fmt.Errorf("loading %s: %w", path, err)
+		}
+		s.detectors[id] = detector
+	}
+	return s, nil
+}
+
+// number is a float64 encoded as null when it is NaN or infinite
+type number float64
+
+func (n number) MarshalJSON() ([]byte, error) {
+	x := float64(n)
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(x)
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, apiError{Error: err.Error()})
+}
+
+// get returns the detector id
+func (s *Server) get(id string) (*gospot.SyncSpot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	detector, ok := s.detectors[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return detector, nil
+}
+
+// save writes the state of the detector into the directory
+func (s *Server) save(id string, detector *gospot.SyncSpot) error {
+	if s.dir == "" {
+		return nil
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	// the detector may have been deleted or replaced in the meantime
+	if current, err := s.get(id); err != nil || current != detector {
+		return nil
+	}
+	// the state is read under the lock so that an older state cannot be
+	// written after a newer one
+	raw, err := json.Marshal(detector)
+	if err != nil {
+		return err
+	}
+	// write then rename so that a crash does not leave a partial state
+	tmp := filepath.Join(s.dir, "."+id+".tmp")
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, id+".json"))
+}
+
+// remove deletes the saved state of the detector
+func (s *Server) remove(id string) error {
+	if s.dir == "" {
+		return nil
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	err := os.Remove(filepath.Join(s.dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// ServeHTTP implements [http.Handler]
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	if parts[0] != "detectors" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown route %s", r.URL.Path))
+		return
+	}
+
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		s.list(w, r)
+		return
+	}
+
+	id := parts[1]
+	if !validID.MatchString(id) || id == "." || id == ".." {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid detector id %q", id))
+		return
+	}
+
+	action := ""
+	if len(parts) == 3 {
+		action = parts[2]
+	}
+
+	type route struct {
+		method string
+		action string
+	}
+	handlers := map[route]func(http.ResponseWriter, *http.Request, string){
+		{http.MethodPost, ""}:           s.create,
+		{http.MethodGet, ""}:            s.download,
+		{http.MethodPut, ""}:            s.upload,
+		{http.MethodDelete, ""}:         s.delete,
+		{http.MethodPost, "fit"}:        s.fit,
+		{http.MethodPost, "step"}:       s.step,
+		{http.MethodGet, "model"}:       s.model,
+		{http.MethodGet, "quantile"}:    s.quantile,
+		{http.MethodGet, "probability"}: s.probability,
+	}
+	handler, ok := handlers[route{r.Method, action}]
+	if !ok {
+		for k := range handlers {
+			if k.action == action {
+				writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown route %s", r.URL.Path))
+		return
+	}
+	handler(w, r, id)
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	ids := make([]string, 0, len(s.detectors))
+	for id := range s.detectors {
+		ids = append(ids, id)
+	}
+	s.mu.RUnlock()
+	sort.Strings(ids)
+	writeJSON(w, http.StatusOK, map[string][]string{"detectors": ids})
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request, id string) {
+	var config gospot.SpotConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	spot, err := config.New()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	detector := gospot.NewSyncSpot(spot)
+
+	s.mu.Lock()
+	if _, ok := s.detectors[id]; ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, fmt.Errorf("detector %q already exists", id))
+		return
+	}
+	s.detectors[id] = detector
+	s.mu.Unlock()
+
+	if err := s.save(id, detector); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, config)
+}
+
+func (s *Server) download(w http.ResponseWriter, r *http.Request, id string) {
+	detector, err := s.get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, detector)
+}
+
+func (s *Server) upload(w http.ResponseWriter, r *http.Request, id string) {
+	detector := &gospot.SyncSpot{}
+	if err := json.NewDecoder(r.Body).Decode(detector); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mu.Lock()
+	_, exists := s.detectors[id]
+	s.detectors[id] = detector
+	s.mu.Unlock()
+
+	if err := s.save(id, detector); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	code := http.StatusOK
+	if !exists {
+		code = http.StatusCreated
+	}
+	writeJSON(w, code, detector)
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	_, ok := s.detectors[id]
+	delete(s.detectors, id)
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	if err := s.remove(id); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type fitRequest struct {
+	Data []float64 `json:"data"`
+}
+
+func (s *Server) fit(w http.ResponseWriter, r *http.Request, id string) {
+	detector, err := s.get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	var req fitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := detector.Fit(req.Data); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err := s.save(id, detector); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newModelResponse(detector.Model()))
+}
+
+type stepRequest struct {
+	Value  *float64  `json:"value"`
+	Values []float64 `json:"values"`
+}
+
+type stepResponse struct {
+	Status   string   `json:"status,omitempty"`
+	Statuses []string `json:"statuses,omitempty"`
+}
+
+func (s *Server) step(w http.ResponseWriter, r *http.Request, id string) {
+	detector, err := s.get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	var req stepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var resp stepResponse
+	switch {
+	case req.Value != nil && req.Values == nil:
+		resp.Status = detector.Step(*req.Value).String()
+	case req.Value == nil && req.Values != nil:
+		resp.Statuses = make([]string, len(req.Values))
+		for i, x := range req.Values {
+			resp.Statuses[i] = detector.Step(x).String()
+		}
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("either 'value' or 'values' must be given"))
+		return
+	}
+
+	if err := s.save(id, detector); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type modelResponse struct {
+	AnomalyThreshold number `json:"anomaly_threshold"`
+	ExcessThreshold  number `json:"excess_threshold"`
+	Gamma            number `json:"gamma"`
+	Sigma            number `json:"sigma"`
+}
+
+func newModelResponse(m *gospot.SpotModel) modelResponse {
+	return modelResponse{
+		AnomalyThreshold: number(m.AnomalyThreshold),
+		ExcessThreshold:  number(m.ExcessThreshold),
+		Gamma:            number(m.Gamma),
+		Sigma:            number(m.Sigma),
+	}
+}
+
+func (s *Server) model(w http.ResponseWriter, r *http.Request, id string) {
+	detector, err := s.get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newModelResponse(detector.Model()))
+}
+
+// floatParam parses a float query parameter
+func floatParam(r *http.Request, name string) (float64, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return 0, fmt.Errorf("missing parameter %q", name)
+	}
+	x, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad parameter %q: %w", name, err)
+	}
+	return x, nil
+}
+
+func (s *Server) quantile(w http.ResponseWriter, r *http.Request, id string) {
+	detector, err := s.get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	q, err := floatParam(r, "q")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]number{"q": number(q), "quantile": number(detector.Quantile(q))})
+}
+
+func (s *Server) probability(w http.ResponseWriter, r *http.Request, id string) {
+	detector, err := s.get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	z, err := floatParam(r, "z")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]number{"z": number(z), "probability": number(detector.Probability(z))})
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func gaussian(size int) []float64 {
+	out := make([]float64, size)
+	for i := range out {
+		out[i] = rand.NormFloat64()
+	}
+	return out
+}
+
+func request(t *testing.T, h http.Handler, method, path string, body interface{}, out interface{}) int {
+	t.Helper()
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, reader))
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: %v (%s)", method, path, err, rec.Body.String())
+		}
+	}
+	return rec.Code
+}
+
+var config = map[string]interface{}{
+	"q":                 1e-4,
+	"low":               false,
+	"discard_anomalies": true,
+	"level":             0.98,
+	"max_excess":        200,
+}
+
+func TestServer(t *testing.T) {
+	s, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := request(t, s, http.MethodPost, "/detectors/cpu", config, nil); code != http.StatusCreated {
+		t.Fatalf("bad status: %d", code)
+	}
+	if code := request(t, s, http.MethodPost, "/detectors/cpu", config, nil); code != http.StatusConflict {
+		t.Errorf("bad status: %d", code)
+	}
+
+	var model map[string]*float64
+	request(t, s, http.MethodGet, "/detectors/cpu/model", nil, &model)
+	if model["anomaly_threshold"] != nil {
+		t.Errorf("threshold must be null before fitting")
+	}
+
+	if code := request(t, s, http.MethodPost, "/detectors/cpu/fit", map[string]interface{}{"data": gaussian(20_000)}, &model); code != http.StatusOK {
+		t.Fatalf("bad status: %d", code)
+	}
+	threshold := *model["anomaly_threshold"]
+	if threshold < 3 || threshold > 6 {
+		t.Errorf("bad anomaly threshold: %v", threshold)
+	}
+
+	var step map[string]interface{}
+	request(t, s, http.MethodPost, "/detectors/cpu/step", map[string]float64{"value": threshold + 1}, &step)
+	if step["status"] != "ANOMALY" {
+		t.Errorf("bad step: %v", step)
+	}
+	request(t, s, http.MethodPost, "/detectors/cpu/step", map[string][]float64{"values": {0, threshold + 1}}, &step)
+	if fmt.Sprint(step["statuses"]) != "[NORMAL ANOMALY]" {
+		t.Errorf("bad step: %v", step)
+	}
+
+	var q map[string]float64
+	request(t, s, http.MethodGet, "/detectors/cpu/quantile?q=1e-4", nil, &q)
+	if math.Abs(q["quantile"]-threshold) > 1e-3 {
+		t.Errorf("bad quantile: %v", q)
+	}
+	request(t, s, http.MethodGet, fmt.Sprintf("/detectors/cpu/probability?z=%v", threshold), nil, &q)
+	if math.Abs(q["probability"]-1e-4) > 1e-5 {
+		t.Errorf("bad probability: %v", q)
+	}
+
+	var list map[string][]string
+	request(t, s, http.MethodGet, "/detectors", nil, &list)
+	if fmt.Sprint(list["detectors"]) != "[cpu]" {
+		t.Errorf("bad list: %v", list)
+	}
+
+	// download the state and upload it as a new detector
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/detectors/cpu", nil))
+	if code := request(t, s, http.MethodPut, "/detectors/copy", rec.Body.Bytes(), nil); code != http.StatusCreated {
+		t.Fatalf("bad status: %d", code)
+	}
+	var other map[string]*float64
+	request(t, s, http.MethodGet, "/detectors/copy/model", nil, &other)
+	if *other["anomaly_threshold"] != *model["anomaly_threshold"] {
+		t.Errorf("state not uploaded")
+	}
+
+	if code := request(t, s, http.MethodDelete, "/detectors/copy", nil, nil); code != http.StatusNoContent {
+		t.Errorf("bad status: %d", code)
+	}
+	if code := request(t, s, http.MethodGet, "/detectors/copy", nil, nil); code != http.StatusNotFound {
+		t.Errorf("bad status: %d", code)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	s, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request(t, s, http.MethodPost, "/detectors/a", config, nil)
+
+	bad := map[string]interface{}{"q": 0.5, "level": 0.98, "max_excess": 10}
+
+	cases := []struct {
+		method string
+		path   string
+		body   interface{}
+		code   int
+	}{
+		{http.MethodGet, "/nope", nil, http.StatusNotFound},
+		{http.MethodGet, "/detectors/b", nil, http.StatusNotFound},
+		{http.MethodGet, "/detectors/a/nope", nil, http.StatusNotFound},
+		{http.MethodPost, "/detectors/a%2Fb", config, http.StatusNotFound},
+		{http.MethodPost, "/detectors/a%20b", config, http.StatusBadRequest},
+		{http.MethodPost, "/detectors/b", bad, http.StatusBadRequest},
+		{http.MethodPost, "/detectors/b", []byte("{"), http.StatusBadRequest},
+		{http.MethodGet, "/detectors/a/fit", nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/detectors/a/fit", map[string][]float64{"data": {1, 1, 1}}, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/detectors/a/step", map[string]interface{}{}, http.StatusBadRequest},
+		{http.MethodGet, "/detectors/a/quantile", nil, http.StatusBadRequest},
+		{http.MethodGet, "/detectors/a/probability?z=x", nil, http.StatusBadRequest},
+		{http.MethodPut, "/detectors/a", []byte("{}"), http.StatusBadRequest},
+		{http.MethodDelete, "/detectors/b", nil, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		var resp map[string]interface{}
+		if code := request(t, s, c.method, c.path, c.body, &resp); code != c.code {
+			t.Errorf("%s %s: bad status %d instead of %d (%v)", c.method, c.path, code, c.code, resp)
+		}
+		if resp["error"] == nil {
+			t.Errorf("%s %s: missing error message", c.method, c.path)
+		}
+	}
+}
+
+func TestServerPersistence(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request(t, s, http.MethodPost, "/detectors/a", config, nil)
+	request(t, s, http.MethodPost, "/detectors/b", config, nil)
+	request(t, s, http.MethodPost, "/detectors/a/fit", map[string]interface{}{"data": gaussian(20_000)}, nil)
+	request(t, s, http.MethodPost, "/detectors/a/step", map[string][]float64{"values": gaussian(1_000)}, nil)
+	request(t, s, http.MethodDelete, "/detectors/b", nil, nil)
+
+	var model map[string]float64
+	request(t, s, http.MethodGet, "/detectors/a/model", nil, &model)
+
+	// restart
+	s, err = New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list map[string][]string
+	request(t, s, http.MethodGet, "/detectors", nil, &list)
+	if fmt.Sprint(list["detectors"]) != "[a]" {
+		t.Errorf("bad list: %v", list)
+	}
+	var restored map[string]float64
+	request(t, s, http.MethodGet, "/detectors/a/model", nil, &restored)
+	if fmt.Sprint(restored) != fmt.Sprint(model) {
+		t.Errorf("model not restored: %v != %v", restored, model)
+	}
+
+	// a corrupted state prevents the server from starting
+	if err := os.WriteFile(filepath.Join(dir, "c.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(dir); err == nil {
+		t.Errorf("must return an error on corrupted state")
+	}
+}
+
+func TestServerConcurrentSaves(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request(t, s, http.MethodPost, "/detectors/a", config, nil)
+	request(t, s, http.MethodPost, "/detectors/a/fit", map[string]interface{}{"data": gaussian(20_000)}, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, x := range gaussian(50) {
+				request(t, s, http.MethodPost, "/detectors/a/step", map[string]float64{"value": x}, nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the last saved state must hold every step
+	var state, saved map[string]interface{}
+	request(t, s, http.MethodGet, "/detectors/a", nil, &state)
+	s, err = New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request(t, s, http.MethodGet, "/detectors/a", nil, &saved)
+	if n, ok := state["n"].(float64); !ok || n <= 20_000 {
+		t.Fatalf("bad number of data: %v", state["n"])
+	}
+	if state["n"] != saved["n"] || state["Nt"] != saved["Nt"] {
+		t.Errorf("stale state saved: n=%v Nt=%v (expected n=%v Nt=%v)", saved["n"], saved["Nt"], state["n"], state["Nt"])
+	}
+}
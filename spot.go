@@ -12,8 +12,35 @@ const (
 	NORMAL
 	EXCESS
 	ANOMALY
+	// Two-sided statuses (see [BiSpot])
+	EXCESS_LOW
+	EXCESS_HIGH
+	ANOMALY_LOW
+	ANOMALY_HIGH
 )
 
+func (status SpotStatus) String() string {
+	switch status {
+	case INTERNAL_ERROR:
+		return "INTERNAL_ERROR"
+	case NORMAL:
+		return "NORMAL"
+	case EXCESS:
+		return "EXCESS"
+	case ANOMALY:
+		return "ANOMALY"
+	case EXCESS_LOW:
+		return "EXCESS_LOW"
+	case EXCESS_HIGH:
+		return "EXCESS_HIGH"
+	case ANOMALY_LOW:
+		return "ANOMALY_LOW"
+	case ANOMALY_HIGH:
+		return "ANOMALY_HIGH"
+	}
+	return fmt.Sprintf("SpotStatus(%d)", int(status))
+}
+
 // Spot represents the main structure to run the SPOT algorithm
 type Spot struct {
 	// Probability of an anomaly
@@ -34,6 +61,33 @@ type Spot struct {
 	AnomalyThreshold float64 `json:"anomaly_threshold"`
 	// Tail threshold
 	ExcessThreshold float64 `json:"excess_threshold"`
+	// When to fit the tail again (nil means on every excess)
+	Refit *RefitPolicy `json:"refit,omitempty"`
+	// Probabilities of the severity tiers in decreasing order (see [Spot.SetTiers])
+	Tiers []float64 `json:"tiers,omitempty"`
+	// Thresholds of the severity tiers
+	TierThresholds []float64 `json:"tier_thresholds,omitempty"`
+	// Tier beyond which the anomalies are discarded (0 means the Q threshold)
+	DiscardTier int `json:"discard_tier,omitempty"`
+	// Runs declustering: excesses separated by fewer than this number of
+	// non-excess steps form a cluster and only its maximum is pushed into
+	// the tail, once the cluster is over (0 disables declustering)
+	Decluster uint64 `json:"decluster,omitempty"`
+	// Number of clusters
+	Clusters uint64 `json:"clusters,omitempty"`
+	// Number of excesses in the current cluster
+	ClusterSize uint64 `json:"cluster_size,omitempty"`
+	// Maximum excess of the current cluster
+	ClusterMax float64 `json:"cluster_max,omitempty"`
+	// Step of the last excess of the current cluster
+	ClusterEnd uint64 `json:"cluster_end,omitempty"`
+	// Online estimator of the excess threshold (see [Spot.SetAdaptive])
+	Tracker *P2 `json:"tracker,omitempty"`
+	// How the confidence interval of the anomaly threshold is computed
+	// (see [Spot.SetConfidence])
+	Confidence *ConfidencePolicy `json:"confidence,omitempty"`
+	// Confidence interval of the anomaly threshold
+	ThresholdInterval *Interval `json:"threshold_interval,omitempty"`
 }
 
 // NewSpot initializes and returns a new Spot instance with the given parameters.
@@ -71,6 +125,54 @@ func NewSpot(q float64, low bool, discardAnomalies bool, level float64, maxExces
 	}, nil
 }
 
+// SpotConfig gathers the parameters of [NewSpot]. It is a convenient
+// template when many detectors share the same settings.
+type SpotConfig struct {
+	Q                float64           `json:"q"`
+	Low              bool              `json:"low"`
+	DiscardAnomalies bool              `json:"discard_anomalies"`
+	Level            float64           `json:"level"`
+	MaxExcess        uint64            `json:"max_excess"`
+	Tiers            []float64         `json:"tiers,omitempty"`
+	DiscardTier      int               `json:"discard_tier,omitempty"`
+	Decluster        uint64            `json:"decluster,omitempty"`
+	Adaptive         bool              `json:"adaptive,omitempty"`
+	Estimators       []string          `json:"estimators,omitempty"`
+	Selection        Selection         `json:"selection,omitempty"`
+	Confidence       *ConfidencePolicy `json:"confidence,omitempty"`
+	Refit            *RefitPolicy      `json:"refit,omitempty"`
+}
+
+// New returns a new Spot instance built from the configuration (see [NewSpot],
+// [Spot.SetTiers] and [Spot.SetConfidence]). Only the criteria of the refit
+// policy are copied.
+func (c SpotConfig) New() (*Spot, error) {
+	spot, err := NewSpot(c.Q, c.Low, c.DiscardAnomalies, c.Level, c.MaxExcess)
+	if err != nil {
+		return nil, err
+	}
+	spot.Decluster = c.Decluster
+	spot.SetAdaptive(c.Adaptive)
+	if err := spot.Tail.SetEstimators(c.Estimators, c.Selection); err != nil {
+		return nil, err
+	}
+	if err := spot.SetConfidence(c.Confidence); err != nil {
+		return nil, err
+	}
+	if c.Refit != nil {
+		if err := c.Refit.check(); err != nil {
+			return nil, err
+		}
+		spot.Refit = &RefitPolicy{Every: c.Refit.Every, Tolerance: c.Refit.Tolerance, Interval: c.Refit.Interval}
+	}
+	if len(c.Tiers) > 0 || c.DiscardTier != 0 {
+		if err := spot.SetTiers(c.Tiers, c.DiscardTier); err != nil {
+			return nil, err
+		}
+	}
+	return spot, nil
+}
+
 func (spot *Spot) upDown() float64 {
 	if spot.Low {
 		return -1.0
@@ -83,9 +185,22 @@ func (s *Spot) Reset() {
 	maxExcess := uint64(len(s.Tail.Peaks.Container.Data))
 	s.Nt = 0
 	s.N = 0
-	s.Tail = NewTail(maxExcess)
+	tail := NewTail(maxExcess)
+	tail.Estimators, tail.Selection = s.Tail.Estimators, s.Tail.Selection
+	s.Tail = tail
 	s.AnomalyThreshold = math.NaN()
 	s.ExcessThreshold = math.NaN()
+	for i := range s.TierThresholds {
+		s.TierThresholds[i] = math.NaN()
+	}
+	s.resetClusters()
+	s.ThresholdInterval = nil
+	if s.Tracker != nil {
+		s.Tracker.Init(s.trackedLevel())
+	}
+	if s.Refit != nil {
+		s.Refit.Reset()
+	}
 }
 
 // Fit the Spot instance against the given values.
@@ -93,9 +208,12 @@ func (s *Spot) Reset() {
 func (spot *Spot) Fit(data []float64) error {
 	spot.Nt = 0
 	spot.N = uint64(len(data))
+	spot.resetClusters()
 
 	var et float64
-	if spot.Low {
+	if spot.Tracker != nil {
+		et = spot.trainTracker(data)
+	} else if spot.Low {
 		et = P2Quantile(1.0-spot.Level, data)
 	} else {
 		et = P2Quantile(spot.Level, data)
@@ -105,17 +223,17 @@ func (spot *Spot) Fit(data []float64) error {
 	}
 	spot.ExcessThreshold = et
 
-	for _, x := range data {
+	for i, x := range data {
 		excess := spot.upDown() * (x - et)
 		if excess > 0 {
 			spot.Nt++
-			spot.Tail.Push(excess)
+			spot.addExcess(excess, uint64(i+1))
 		}
 	}
+	spot.flushCluster()
 
-	spot.Tail.Fit()
-
-	spot.AnomalyThreshold = spot.Quantile(spot.Q)
+	spot.refit()
+	spot.updateBootstrap()
 	if math.IsNaN(spot.AnomalyThreshold) {
 		return fmt.Errorf("anomaly threshold is NaN")
 	}
@@ -129,28 +247,118 @@ func (spot *Spot) Fit(data []float64) error {
 //   - [EXCESS]: the data is in the tail of the distribution and has triggered a model update
 //   - [NORMAL]: nothing to say
 //   - [INTERNAL_ERROR]: the input value is NaN
+//
+// With declustering, the model is updated when a cluster is over, so
+// possibly after a normal value. With a discarded tier (see
+// [Spot.SetTiers]), the anomalies below it still update the model.
 func (spot *Spot) Step(x float64) SpotStatus {
+	status, changed := spot.push(x)
+	if changed && spot.refitDue() {
+		spot.refit()
+	}
+	return status
+}
+
+// StepResult details the outcome of a step (see [Spot.StepDetailed])
+type StepResult struct {
+	// Status returned by the step
+	Status SpotStatus
+	// Tail probability P(X>x) of the value before the model update
+	// (NaN if the detector is not fitted)
+	Probability float64
+	// Normalised score -log10(Probability) (the higher, the more abnormal)
+	Score float64
+	// Expected number of samples between two values as extreme (1/Probability)
+	ReturnPeriod float64
+	// Highest severity tier crossed by the value (0 if none, see [Spot.Tier])
+	Tier int
+	// Normal/abnormal threshold in force when the decision was made
+	AnomalyThreshold float64
+	// Tail threshold in force when the decision was made
+	ExcessThreshold float64
+	// Confidence interval of the anomaly threshold in force (NaN bounds and
+	// a zero level if the detector has no confidence policy, see
+	// [Spot.SetConfidence])
+	ThresholdInterval Interval
+}
+
+// StepDetailed updates the Spot instance with a fresh value x like
+// [Spot.Step] but also reports the probability of x and the thresholds
+// that were used to take the decision
+func (spot *Spot) StepDetailed(x float64) StepResult {
+	result := spot.detail(x)
+	result.Status = spot.Step(x)
+	return result
+}
+
+// detail computes the step result of x against the current model
+// (the status is not set)
+func (spot *Spot) detail(x float64) StepResult {
+	p := math.NaN()
+	if !math.IsNaN(x) {
+		// below the excess threshold, the tail approximation exceeds 1
+		p = math.Max(0.0, math.Min(1.0, spot.Probability(x)))
+	}
+	interval := Interval{Lower: math.NaN(), Upper: math.NaN()}
+	if spot.ThresholdInterval != nil {
+		interval = *spot.ThresholdInterval
+	}
+	return StepResult{
+		ThresholdInterval: interval,
+		Probability:       p,
+		Tier:              spot.Tier(x),
+		Score:             -math.Log10(p),
+		ReturnPeriod:      1.0 / p,
+		AnomalyThreshold:  spot.AnomalyThreshold,
+		ExcessThreshold:   spot.ExcessThreshold,
+	}
+}
+
+// push updates the counters and the peaks with x but does not fit the
+// tail. It also tells whether the peaks have changed.
+func (spot *Spot) push(x float64) (SpotStatus, bool) {
 	if math.IsNaN(x) {
-		return INTERNAL_ERROR
+		return INTERNAL_ERROR, false
 	}
 
-	// flag anomaly
-	if spot.DiscardAnomalies && spot.upDown()*(x-spot.AnomalyThreshold) > 0 {
-		return ANOMALY
+	// flag anomaly (the ones below the discarded tier are kept in the model)
+	anomaly := spot.DiscardAnomalies && spot.upDown()*(x-spot.AnomalyThreshold) > 0
+	if anomaly && spot.upDown()*(x-spot.discardThreshold()) > 0 {
+		return ANOMALY, false
 	}
 
 	spot.N++
+	if spot.Tracker != nil {
+		spot.Tracker.Push(x)
+	}
 
+	status, changed := NORMAL, false
 	ex := spot.upDown() * (x - spot.ExcessThreshold)
 	if ex >= 0.0 {
 		spot.Nt++
-		spot.Tail.Push(ex)
-		spot.Tail.Fit()
-		spot.AnomalyThreshold = spot.Quantile(spot.Q)
-		return EXCESS
+		status, changed = EXCESS, spot.addExcess(ex, spot.N)
+	} else {
+		changed = spot.endCluster(spot.N)
+	}
+	if anomaly {
+		status = ANOMALY
 	}
+	return status, changed
+}
+
+// refitDue tells whether the tail must be fitted after a change of the peaks
+func (spot *Spot) refitDue() bool {
+	return spot.Refit == nil || spot.Refit.due(spot.Tail.Peaks)
+}
 
-	return NORMAL
+// refit fits the tail and updates the thresholds
+func (spot *Spot) refit() {
+	spot.adapt()
+	spot.Tail.Fit()
+	spot.updateThresholds()
+	if spot.Refit != nil {
+		spot.Refit.fitted(spot.Tail.Peaks)
+	}
 }
 
 // Quantile computes the value zq such that P(X>zq) = q
@@ -235,3 +235,90 @@ func Example() {
 
 	fmt.Printf("ANOMALY:%d EXCESS:%d NORMAL:%d\n", A, E, N)
 }
+
+func TestStatusString(t *testing.T) {
+	if ANOMALY.String() != "ANOMALY" || EXCESS_LOW.String() != "EXCESS_LOW" {
+		t.Errorf("bad status names: %v, %v", ANOMALY, EXCESS_LOW)
+	}
+	if SpotStatus(42).String() != "SpotStatus(42)" {
+		t.Errorf("bad unknown status name: %v", SpotStatus(42))
+	}
+}
+
+func TestStepDetailed(t *testing.T) {
+	s := defaultSpot()
+	if r := s.StepDetailed(1.0); !math.IsNaN(r.Probability) || !math.IsNaN(r.AnomalyThreshold) {
+		t.Errorf("probability must be NaN before fitting: %+v", r)
+	}
+	s.Reset()
+	s.Fit(gaussian(100_000))
+
+	// anomaly
+	x := s.AnomalyThreshold + 1
+	p := s.Probability(x)
+	r := s.StepDetailed(x)
+	if r.Status != ANOMALY || r.Probability != p || p >= s.Q {
+		t.Errorf("bad anomaly result: %+v", r)
+	}
+	if r.Score != -math.Log10(p) || r.ReturnPeriod != 1/p {
+		t.Errorf("bad score or return period: %+v", r)
+	}
+
+	// an excess updates the model, the result keeps the previous thresholds
+	at, et := s.AnomalyThreshold, s.ExcessThreshold
+	r = s.StepDetailed(et + 0.5*(at-et))
+	if r.Status != EXCESS || r.AnomalyThreshold != at || r.ExcessThreshold != et {
+		t.Errorf("bad excess result: %+v", r)
+	}
+	if r.Probability <= s.Q || r.Probability >= 1-s.Level {
+		t.Errorf("bad excess probability: %v", r.Probability)
+	}
+	if s.AnomalyThreshold == at {
+		t.Errorf("the model must be updated after an excess")
+	}
+
+	// normal values are clamped
+	r = s.StepDetailed(s.ExcessThreshold - 10)
+	if r.Status != NORMAL || r.Probability > 1 || r.Score < 0 || r.ReturnPeriod < 1 {
+		t.Errorf("bad normal result: %+v", r)
+	}
+
+	r = s.StepDetailed(math.NaN())
+	if r.Status != INTERNAL_ERROR || !math.IsNaN(r.Probability) {
+		t.Errorf("bad NaN result: %+v", r)
+	}
+
+	// no allocation on the steps that do not fit the tail again
+	allocs := testing.AllocsPerRun(100, func() {
+		s.StepDetailed(0.0)
+		s.StepDetailed(s.AnomalyThreshold + 1)
+	})
+	if allocs != 0 {
+		t.Errorf("StepDetailed must not allocate (%v allocations)", allocs)
+	}
+
+	// nor when the detector reports a confidence interval
+	if err := s.SetConfidence(&ConfidencePolicy{Level: 0.9}); err != nil {
+		t.Fatal(err)
+	}
+	allocs = testing.AllocsPerRun(100, func() {
+		s.StepDetailed(0.0)
+		s.StepDetailed(s.AnomalyThreshold + 1)
+	})
+	if allocs != 0 {
+		t.Errorf("StepDetailed must not allocate with a confidence policy (%v allocations)", allocs)
+	}
+
+	// an excess fits the tail again, the result keeps the interval in force
+	// and allocates nothing more than the step
+	interval := *s.ThresholdInterval
+	r = s.StepDetailed(s.ExcessThreshold + 0.01)
+	if r.Status != EXCESS || r.ThresholdInterval != interval || *s.ThresholdInterval == interval {
+		t.Errorf("bad interval of the excess result: %+v (in force %+v)", r.ThresholdInterval, interval)
+	}
+	step := testing.AllocsPerRun(100, func() { s.Step(s.ExcessThreshold + 0.01) })
+	detailed := testing.AllocsPerRun(100, func() { s.StepDetailed(s.ExcessThreshold + 0.01) })
+	if detailed > step {
+		t.Errorf("StepDetailed must not allocate more than Step on an excess (%v > %v allocations)", detailed, step)
+	}
+}
@@ -0,0 +1,207 @@
+package gospot
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// SpotModel is an immutable view of the model of a detector
+type SpotModel struct {
+	// Normal/abnormal threshold
+	AnomalyThreshold float64
+	// Tail threshold
+	ExcessThreshold float64
+	// GPD gamma parameter
+	Gamma float64
+	// GPD sigma parameter
+	Sigma float64
+}
+
+// SyncSpot wraps a [Spot] instance so that it can be used concurrently.
+// Mutations are serialized by a lock while the current model can be read
+// without locking through [SyncSpot.Model].
+type SyncSpot struct {
+	mu    sync.RWMutex
+	spot  *Spot
+	model atomic.Pointer[SpotModel]
+	// incremented on every mutation that is not a step
+	epoch uint64
+}
+
+// NewSyncSpot wraps the given detector. It must not be used directly
+// afterwards.
+func NewSyncSpot(spot *Spot) *SyncSpot {
+	s := &SyncSpot{spot: spot}
+	s.publish()
+	return s
+}
+
+// publish stores a new model if it has changed. It must be called with
+// the write lock held.
+func (s *SyncSpot) publish() {
+	model := SpotModel{
+		AnomalyThreshold: s.spot.AnomalyThreshold,
+		ExcessThreshold:  s.spot.ExcessThreshold,
+		Gamma:            s.spot.Tail.Gamma,
+		Sigma:            s.spot.Tail.Sigma,
+	}
+	if current := s.model.Load(); current == nil || !sameModel(*current, model) {
+		s.model.Store(&model)
+	}
+}
+
+// sameModel compares two models (NaN values are considered equal)
+func sameModel(a, b SpotModel) bool {
+	same := func(x, y float64) bool { return x == y || (math.IsNaN(x) && math.IsNaN(y)) }
+	return same(a.AnomalyThreshold, b.AnomalyThreshold) &&
+		same(a.ExcessThreshold, b.ExcessThreshold) &&
+		same(a.Gamma, b.Gamma) &&
+		same(a.Sigma, b.Sigma)
+}
+
+// Model returns the current model. It does not lock and the returned value
+// must not be modified.
+func (s *SyncSpot) Model() *SpotModel {
+	return s.model.Load()
+}
+
+// Do runs f with an exclusive access to the underlying detector. The model
+// is published afterwards.
+func (s *SyncSpot) Do(f func(spot *Spot)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s.spot)
+	s.epoch++
+	s.publish()
+}
+
+// View runs f with a shared access to the underlying detector. f must not
+// modify it.
+func (s *SyncSpot) View(f func(spot *Spot)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f(s.spot)
+}
+
+// Reset puts the detector into its initial state (see [Spot.Reset])
+func (s *SyncSpot) Reset() {
+	s.Do(func(spot *Spot) { spot.Reset() })
+}
+
+// Fit the detector against the given values (see [Spot.Fit])
+func (s *SyncSpot) Fit(data []float64) (err error) {
+	s.Do(func(spot *Spot) { err = spot.Fit(data) })
+	return
+}
+
+// Step updates the detector with a fresh value x (see [Spot.Step])
+func (s *SyncSpot) Step(x float64) SpotStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.spot.Step(x)
+	s.publish()
+	return status
+}
+
+// StepDetailed updates the detector with a fresh value x (see [Spot.StepDetailed])
+func (s *SyncSpot) StepDetailed(x float64) StepResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := s.spot.StepDetailed(x)
+	s.publish()
+	return result
+}
+
+// RefreshInterval computes the confidence interval of the anomaly threshold
+// (see [Spot.RefreshInterval]). It works on a copy of the model so that
+// the other calls are not blocked meanwhile. The interval is dropped if
+// the detector is fitted, reset or modified by [SyncSpot.Do] in the
+// meantime, or if its tail has been fitted again by a step.
+func (s *SyncSpot) RefreshInterval() error {
+	s.mu.RLock()
+	if s.spot.Confidence == nil {
+		s.mu.RUnlock()
+		return fmt.Errorf("the detector has no confidence policy")
+	}
+	copied := &Spot{
+		Q:                s.spot.Q,
+		Low:              s.spot.Low,
+		Nt:               s.spot.Nt,
+		N:                s.spot.N,
+		Tail:             s.spot.Tail.Clone(),
+		ExcessThreshold:  s.spot.ExcessThreshold,
+		AnomalyThreshold: s.spot.AnomalyThreshold,
+	}
+	policy := *s.spot.Confidence
+	epoch := s.epoch
+	s.mu.RUnlock()
+
+	interval, err := copied.computeInterval(policy)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.epoch == epoch && s.spot.Confidence != nil && sameFit(s.spot, copied) {
+		s.spot.ThresholdInterval = &interval
+	}
+	return err
+}
+
+// sameFit tells whether two detectors have the same thresholds and GPD
+// parameters
+func sameFit(a, b *Spot) bool {
+	return a.AnomalyThreshold == b.AnomalyThreshold &&
+		a.ExcessThreshold == b.ExcessThreshold &&
+		a.Tail.Gamma == b.Tail.Gamma &&
+		a.Tail.Sigma == b.Tail.Sigma
+}
+
+// Quantile computes the value zq such that P(X>zq) = q (see [Spot.Quantile])
+func (s *SyncSpot) Quantile(q float64) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.spot.Quantile(q)
+}
+
+// Probability computes the probability p such that P(X>z) = p (see [Spot.Probability])
+func (s *SyncSpot) Probability(z float64) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.spot.Probability(z)
+}
+
+// MarshalJSON encodes the underlying detector
+func (s *SyncSpot) MarshalJSON() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return json.Marshal(s.spot)
+}
+
+// UnmarshalJSON replaces the underlying detector
+func (s *SyncSpot) UnmarshalJSON(data []byte) error {
+	spot := &Spot{}
+	if err := json.Unmarshal(data, spot); err != nil {
+		return err
+	}
+	s.Do(func(*Spot) { s.spot = spot })
+	return nil
+}
+
+// MarshalBinary encodes the underlying detector (see [Spot.MarshalBinary])
+func (s *SyncSpot) MarshalBinary() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.spot.MarshalBinary()
+}
+
+// UnmarshalBinary replaces the underlying detector (see [Spot.UnmarshalBinary])
+func (s *SyncSpot) UnmarshalBinary(data []byte) error {
+	spot := &Spot{}
+	if err := spot.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	s.Do(func(*Spot) { s.spot = spot })
+	return nil
+}
@@ -0,0 +1,126 @@
+package gospot
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestSyncSpotModel(t *testing.T) {
+	s := NewSyncSpot(defaultSpot())
+	m := s.Model()
+	if m == nil || !math.IsNaN(m.AnomalyThreshold) {
+		t.Fatalf("model must be published before fitting")
+	}
+
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+	m = s.Model()
+	s.View(func(spot *Spot) {
+		if m.AnomalyThreshold != spot.AnomalyThreshold || m.ExcessThreshold != spot.ExcessThreshold ||
+			m.Gamma != spot.Tail.Gamma || m.Sigma != spot.Tail.Sigma {
+			t.Errorf("model not published after fit")
+		}
+	})
+
+	// a normal value does not change the model
+	s.Step(m.ExcessThreshold - 1)
+	if s.Model() != m {
+		t.Errorf("model must not be republished")
+	}
+	s.Step(m.ExcessThreshold + 0.1)
+	if s.Model() == m {
+		t.Errorf("model must be republished after an excess")
+	}
+}
+
+func TestSyncSpotRace(t *testing.T) {
+	s := NewSyncSpot(defaultSpot())
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				m := s.Model()
+				if m.AnomalyThreshold <= m.ExcessThreshold {
+					t.Errorf("inconsistent model: %+v", m)
+				}
+				s.Quantile(1e-3)
+				s.Probability(m.AnomalyThreshold)
+				if _, err := json.Marshal(s); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+
+	for _, x := range gaussian(2_000) {
+		s.Step(x)
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestSyncSpotState(t *testing.T) {
+	s := NewSyncSpot(defaultSpot())
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &SyncSpot{}
+	if err := json.Unmarshal(raw, other); err != nil {
+		t.Fatal(err)
+	}
+	if *other.Model() != *s.Model() {
+		t.Errorf("model not restored: %+v != %+v", other.Model(), s.Model())
+	}
+
+	raw, err = s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other = &SyncSpot{}
+	if err := other.UnmarshalBinary(raw); err != nil {
+		t.Fatal(err)
+	}
+	if *other.Model() != *s.Model() {
+		t.Errorf("model not restored: %+v != %+v", other.Model(), s.Model())
+	}
+
+	s.Reset()
+	if !math.IsNaN(s.Model().AnomalyThreshold) {
+		t.Errorf("model not reset")
+	}
+}
+
+func TestSyncSpotStepDetailed(t *testing.T) {
+	s := NewSyncSpot(defaultSpot())
+	if err := s.Fit(gaussian(50_000)); err != nil {
+		t.Fatal(err)
+	}
+	m := s.Model()
+	r := s.StepDetailed(m.ExcessThreshold + 0.1)
+	if r.Status != EXCESS || r.AnomalyThreshold != m.AnomalyThreshold || r.ExcessThreshold != m.ExcessThreshold {
+		t.Errorf("bad result: %+v", r)
+	}
+	if s.Model() == m {
+		t.Errorf("model must be republished after an excess")
+	}
+}
@@ -1,9 +1,21 @@
 package gospot
 
 import (
+	"fmt"
 	"math"
 )
 
+// Selection tells how the parameters of a [Tail] are chosen among the
+// results of its estimators
+type Selection string
+
+const (
+	// SelectMaxLikelihood keeps the estimate with the highest likelihood (default)
+	SelectMaxLikelihood Selection = "max_likelihood"
+	// SelectPreference keeps the first valid estimate in the order of the estimators
+	SelectPreference Selection = "preference"
+)
+
 type Tail struct {
 	// GPD gamma parameter
 	Gamma float64 `json:"gamma"`
@@ -11,6 +23,12 @@ type Tail struct {
 	Sigma float64 `json:"sigma"`
 	// Underlyning Peaks structure
 	Peaks *Peaks `json:"peaks"`
+	// Names of the estimators (empty means [DefaultEstimators])
+	Estimators []string `json:"estimators,omitempty"`
+	// How the estimate is chosen (empty means [SelectMaxLikelihood])
+	Selection Selection `json:"selection,omitempty"`
+	// Name of the estimator that has won the last fit
+	Winner string `json:"winner,omitempty"`
 }
 
 // NewTail initializes a new GPD tail
@@ -22,6 +40,14 @@ func NewTail(size uint64) *Tail {
 	}
 }
 
+// Clone returns a deep copy of the tail
+func (tail *Tail) Clone() *Tail {
+	out := *tail
+	out.Peaks = tail.Peaks.Clone()
+	out.Estimators = append([]string(nil), tail.Estimators...)
+	return &out
+}
+
 // Push adds a new data in the tail
 func (tail *Tail) Push(x float64) {
 	tail.Peaks.Push(x)
@@ -33,6 +59,10 @@ func (tail *Tail) Probability(s, d float64) float64 {
 		return s * math.Exp(-d/tail.Sigma)
 	} else {
 		r := d * (tail.Gamma / tail.Sigma)
+		if 1.0+r <= 0.0 {
+			// beyond the upper bound of the distribution (gamma < 0)
+			return 0.0
+		}
 		return s * math.Pow(1.0+r, -1.0/tail.Gamma)
 	}
 }
@@ -45,16 +75,66 @@ func (tail *Tail) Quantile(s, q float64) float64 {
 	return (tail.Sigma / tail.Gamma) * (math.Pow(r, -tail.Gamma) - 1)
 }
 
-// Fit the tail against the pushed data
+// SetEstimators configures the estimators of the tail (they must be
+// registered, see [RegisterEstimator]) and how the winner is chosen
+func (tail *Tail) SetEstimators(names []string, selection Selection) error {
+	if err := checkEstimators(names, selection); err != nil {
+		return err
+	}
+	tail.Estimators = append([]string(nil), names...)
+	tail.Selection = selection
+	return nil
+}
+
+// checkEstimators checks that the estimators are registered and that the
+// selection is known
+func checkEstimators(names []string, selection Selection) error {
+	for _, name := range names {
+		if _, ok := LookupEstimator(name); !ok {
+			return fmt.Errorf("unknown estimator %q", name)
+		}
+	}
+	switch selection {
+	case "", SelectMaxLikelihood, SelectPreference:
+		return nil
+	}
+	return fmt.Errorf("unknown selection %q", selection)
+}
+
+// validEstimate tells whether an estimate can be used
+func validEstimate(gamma, sigma, llhood float64) bool {
+	return !math.IsNaN(gamma) && !math.IsInf(gamma, 0) && sigma > 0 && !math.IsInf(sigma, 0) && !math.IsNaN(llhood)
+}
+
+// Fit the tail against the pushed data. It returns the log-likelihood of
+// the chosen estimate. Unregistered estimators are skipped. With
+// [SelectPreference], the parameters are kept when no estimate is valid.
 func (tail *Tail) Fit() float64 {
+	names := tail.Estimators
+	if len(names) == 0 {
+		names = DefaultEstimators
+	}
 	maxLLhood := math.NaN()
+	tail.Winner = ""
 
-	for _, e := range []Estimator{tail.Peaks.MomEstimator, tail.Peaks.GrimshawEstimator} {
-		gamma, sigma, llhood := e()
+	for _, name := range names {
+		e, ok := LookupEstimator(name)
+		if !ok {
+			continue
+		}
+		gamma, sigma, llhood := e.Estimate(tail.Peaks)
+		if tail.Selection == SelectPreference {
+			if validEstimate(gamma, sigma, llhood) {
+				tail.Gamma, tail.Sigma, tail.Winner = gamma, sigma, name
+				return llhood
+			}
+			continue
+		}
 		if math.IsNaN(maxLLhood) || (llhood > maxLLhood) {
 			maxLLhood = llhood
 			tail.Gamma = gamma
 			tail.Sigma = sigma
+			tail.Winner = name
 		}
 	}
 
@@ -204,3 +204,19 @@ func TestProbabilityQuantileLogGaussian(t *testing.T) {
 		t.Logf("Success rate: %f%%", 100*result)
 	}
 }
+
+func TestProbabilityUpperBound(t *testing.T) {
+	tail := NewTail(10)
+	tail.Gamma = -0.5
+	tail.Sigma = 1.0
+
+	// the support of the GPD is [0, -sigma/gamma]
+	if p := tail.Probability(0.1, 1.0); p <= 0.0 {
+		t.Errorf("bad probability: %v", p)
+	}
+	for _, d := range []float64{2.0, 3.0} {
+		if p := tail.Probability(0.1, d); p != 0.0 {
+			t.Errorf("probability beyond the upper bound must be 0: %v", p)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package gospot
+
+import (
+	"fmt"
+	"math"
+)
+
+// SetTiers defines severity tiers on top of the anomaly threshold. The
+// tiers are decision probabilities in decreasing order (like 1e-3, 1e-4
+// and 1e-6 for warning, major and critical) whose thresholds are computed
+// from the same tail. When anomalies are discarded, discard gives the tier
+// beyond which the values are not included in the model (1 for the first
+// one, 0 keeps the anomaly threshold). Calling it with no tiers removes
+// them.
+func (spot *Spot) SetTiers(tiers []float64, discard int) error {
+	for i, q := range tiers {
+		if q <= 0.0 || q >= 1.0-spot.Level {
+			return fmt.Errorf("tier probabilities must be in (0, 1-level)")
+		}
+		if i > 0 && q >= tiers[i-1] {
+			return fmt.Errorf("tier probabilities must be in decreasing order")
+		}
+	}
+	if discard < 0 || discard > len(tiers) {
+		return fmt.Errorf("discard tier must be in [0, %d]", len(tiers))
+	}
+
+	spot.Tiers, spot.TierThresholds = nil, nil
+	spot.DiscardTier = discard
+	if len(tiers) == 0 {
+		return nil
+	}
+	spot.Tiers = append([]float64(nil), tiers...)
+	spot.TierThresholds = make([]float64, len(tiers))
+	for i, q := range spot.Tiers {
+		spot.TierThresholds[i] = math.NaN()
+		if !math.IsNaN(spot.AnomalyThreshold) {
+			spot.TierThresholds[i] = spot.Quantile(q)
+		}
+	}
+	return nil
+}
+
+// checkTiers checks the consistency of decoded tiers
+func (spot *Spot) checkTiers() error {
+	if len(spot.TierThresholds) != len(spot.Tiers) {
+		return fmt.Errorf("spot: %d tier thresholds for %d tiers", len(spot.TierThresholds), len(spot.Tiers))
+	}
+	if spot.DiscardTier < 0 || spot.DiscardTier > len(spot.Tiers) {
+		return fmt.Errorf("spot: discard tier (%d) out of range", spot.DiscardTier)
+	}
+	return nil
+}
+
+// Tier returns the highest severity tier crossed by x (1 for the first
+// one) or 0 if none
+func (spot *Spot) Tier(x float64) int {
+	for i := len(spot.TierThresholds) - 1; i >= 0; i-- {
+		if spot.upDown()*(x-spot.TierThresholds[i]) > 0 {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// discardThreshold returns the threshold beyond which the anomalies are
+// discarded
+func (spot *Spot) discardThreshold() float64 {
+	if spot.DiscardTier > 0 && spot.DiscardTier <= len(spot.TierThresholds) {
+		return spot.TierThresholds[spot.DiscardTier-1]
+	}
+	return spot.AnomalyThreshold
+}
+
+// updateThresholds computes the anomaly and tier thresholds from the tail
+// (and the confidence interval of the anomaly threshold)
+func (spot *Spot) updateThresholds() {
+	spot.AnomalyThreshold = spot.Quantile(spot.Q)
+	if len(spot.TierThresholds) != len(spot.Tiers) {
+		spot.TierThresholds = make([]float64, len(spot.Tiers))
+	}
+	for i, q := range spot.Tiers {
+		spot.TierThresholds[i] = spot.Quantile(q)
+	}
+	spot.updateInterval()
+}
@@ -0,0 +1,220 @@
+package gospot
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func tieredSpot(t *testing.T, low bool) *Spot {
+	t.Helper()
+	s := defaultSpot().WithQ(1e-3)
+	s.Low = low
+	if err := s.SetTiers([]float64{1e-3, 1e-4, 1e-6}, 0); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSetTiers(t *testing.T) {
+	s := defaultSpot()
+	bad := map[string]func() error{
+		"zero":       func() error { return s.SetTiers([]float64{1e-3, 0}, 0) },
+		"too high":   func() error { return s.SetTiers([]float64{0.05}, 0) },
+		"increasing": func() error { return s.SetTiers([]float64{1e-4, 1e-3}, 0) },
+		"duplicate":  func() error { return s.SetTiers([]float64{1e-4, 1e-4}, 0) },
+		"discard":    func() error { return s.SetTiers([]float64{1e-4}, 2) },
+		"negative":   func() error { return s.SetTiers([]float64{1e-4}, -1) },
+	}
+	for name, f := range bad {
+		if err := f(); err == nil {
+			t.Errorf("%s: must return an error", name)
+		}
+	}
+
+	s = tieredSpot(t, false)
+	if len(s.TierThresholds) != 3 || !math.IsNaN(s.TierThresholds[0]) {
+		t.Errorf("tier thresholds must be NaN before fitting: %v", s.TierThresholds)
+	}
+	s.Fit(gaussian(100_000))
+	for i := 1; i < len(s.TierThresholds); i++ {
+		if s.TierThresholds[i] <= s.TierThresholds[i-1] {
+			t.Errorf("tier thresholds must increase: %v", s.TierThresholds)
+		}
+	}
+	if s.TierThresholds[0] != s.AnomalyThreshold {
+		t.Errorf("the first tier has the probability q: %v != %v", s.TierThresholds[0], s.AnomalyThreshold)
+	}
+	for i, q := range s.Tiers {
+		if math.Abs(s.Probability(s.TierThresholds[i])-q) > 1e-9*q {
+			t.Errorf("bad threshold of the tier %v", q)
+		}
+	}
+
+	// tiers can be set after fitting
+	if err := s.SetTiers([]float64{1e-5}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if s.TierThresholds[0] != s.Quantile(1e-5) {
+		t.Errorf("tier threshold must be computed")
+	}
+	if err := s.SetTiers(nil, 0); err != nil || s.Tiers != nil || s.TierThresholds != nil {
+		t.Errorf("tiers must be removed")
+	}
+
+	s.SetTiers([]float64{1e-5}, 0)
+	s.Reset()
+	if !math.IsNaN(s.TierThresholds[0]) {
+		t.Errorf("tier thresholds must be reset")
+	}
+}
+
+func TestTier(t *testing.T) {
+	for _, low := range []bool{false, true} {
+		s := tieredSpot(t, low)
+		if s.Tier(100) != 0 || s.Tier(-100) != 0 {
+			t.Errorf("no tier before fitting")
+		}
+		s.Fit(gaussian(100_000))
+		ud := s.upDown()
+		th := s.TierThresholds
+		cases := map[float64]int{
+			s.ExcessThreshold:                    0,
+			ud*0.01 + th[0]:                      1,
+			th[1] + ud*0.5*math.Abs(th[2]-th[1]): 2,
+			th[2] + ud:                           3,
+		}
+		for x, tier := range cases {
+			if got := s.Tier(x); got != tier {
+				t.Errorf("low=%v: bad tier of %v: %d (expected %d)", low, x, got, tier)
+			}
+			if r := s.StepDetailed(x); r.Tier != tier {
+				t.Errorf("low=%v: bad tier in the step result of %v: %d", low, x, r.Tier)
+			}
+		}
+	}
+}
+
+func TestDiscardTier(t *testing.T) {
+	s := tieredSpot(t, false)
+	s.DiscardTier = 3
+	s.Fit(gaussian(100_000))
+
+	// between the anomaly threshold and the discarded tier, the value is
+	// flagged but kept
+	n := s.N
+	x := s.TierThresholds[1] + 0.01
+	if status := s.Step(x); status != ANOMALY || s.N != n+1 {
+		t.Errorf("value below the discarded tier must be flagged and kept: %v", status)
+	}
+	if status := s.Step(s.TierThresholds[2] + 1); status != ANOMALY || s.N != n+1 {
+		t.Errorf("value beyond the discarded tier must be discarded: %v", status)
+	}
+
+	s.DiscardTier = 0
+	if status := s.Step(s.AnomalyThreshold + 0.01); status != ANOMALY {
+		t.Errorf("value beyond the anomaly threshold must be discarded: %v", status)
+	}
+}
+
+func TestBiSpotDiscardTier(t *testing.T) {
+	b, err := NewBiSpot(1e-3, 1e-3, true, 0.98, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []*Spot{b.Upper, b.Lower} {
+		if err := s.SetTiers([]float64{1e-4, 1e-6}, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Fit(gaussian(100_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		x      float64
+		status SpotStatus
+		kept   bool
+	}{
+		{b.Upper.TierThresholds[0] + 0.01, ANOMALY_HIGH, true},
+		{b.Upper.TierThresholds[1] + 1, ANOMALY_HIGH, false},
+		{b.Lower.TierThresholds[0] - 0.01, ANOMALY_LOW, true},
+		{b.Lower.TierThresholds[1] - 1, ANOMALY_LOW, false},
+	} {
+		nu, nl := b.Upper.N, b.Lower.N
+		if status := b.Step(c.x); status != c.status {
+			t.Errorf("bad status of %v: %v", c.x, status)
+		}
+		n := nu
+		if c.kept {
+			n++
+		}
+		if b.Upper.N != n || b.Lower.N != nl+n-nu {
+			t.Errorf("both tails must count the same data (kept=%v): %d/%d", c.kept, b.Upper.N-nu, b.Lower.N-nl)
+		}
+	}
+}
+
+func TestTiersState(t *testing.T) {
+	s := tieredSpot(t, false)
+	s.DiscardTier = 2
+
+	for _, fitted := range []bool{false, true} {
+		if fitted {
+			s.Fit(gaussian(50_000))
+		}
+		raw, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromBinary := &Spot{}
+		if err := fromBinary.UnmarshalBinary(raw); err != nil {
+			t.Fatal(err)
+		}
+		raw, err = json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromJSON := &Spot{}
+		if err := json.Unmarshal(raw, fromJSON); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, other := range []*Spot{fromBinary, fromJSON} {
+			if other.DiscardTier != 2 || len(other.Tiers) != 3 || len(other.TierThresholds) != 3 {
+				t.Fatalf("tiers not restored: %+v", other)
+			}
+			for i := range s.Tiers {
+				same := other.TierThresholds[i] == s.TierThresholds[i] ||
+					(math.IsNaN(other.TierThresholds[i]) && math.IsNaN(s.TierThresholds[i]))
+				if other.Tiers[i] != s.Tiers[i] || !same {
+					t.Errorf("tier %d not restored", i)
+				}
+			}
+		}
+	}
+
+	for _, raw := range []string{
+		`{"tail":{"peaks":{"container":{"capacity":0,"data":[]}}},"tiers":[1e-3],"tier_thresholds":[]}`,
+		`{"tail":{"peaks":{"container":{"capacity":0,"data":[]}}},"tiers":[1e-3],"tier_thresholds":["NaN"],"discard_tier":2}`,
+	} {
+		if err := json.Unmarshal([]byte(raw), &Spot{}); err == nil {
+			t.Errorf("must return an error: %s", raw)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"tail":{"peaks":{"container":{"capacity":0,"data":[]}}},"tiers":[1e-3],"tier_thresholds":["NaN"],"discard_tier":1}`), &Spot{}); err != nil {
+		t.Errorf("valid tiers: %v", err)
+	}
+}
+
+func TestSpotConfigTiers(t *testing.T) {
+	c := SpotConfig{Q: 1e-3, Level: 0.98, MaxExcess: 100, Tiers: []float64{1e-3, 1e-5}, DiscardTier: 2}
+	s, err := c.New()
+	if err != nil || len(s.Tiers) != 2 || s.DiscardTier != 2 {
+		t.Errorf("tiers not set: %v", err)
+	}
+	c.Tiers = []float64{1e-5, 1e-3}
+	if _, err := c.New(); err == nil {
+		t.Errorf("must return an error on invalid tiers")
+	}
+}
@@ -2,6 +2,7 @@ package gospot
 
 import (
 	"math"
+	"slices"
 )
 
 type Ubend struct {
@@ -53,3 +54,33 @@ func (ubend *Ubend) Push(x float64) float64 {
 
 	return ubend.LastErasedData
 }
+
+// Chronological returns a copy of the stored values, from the oldest to the newest
+func (ubend *Ubend) Chronological() []float64 {
+	out := make([]float64, 0, ubend.Size())
+	if ubend.Filled {
+		out = append(out, ubend.Data[ubend.Cursor:]...)
+	}
+	return append(out, ubend.Data[:ubend.Cursor]...)
+}
+
+// Sorted returns a copy of the stored values in ascending order (the
+// container is not modified)
+func (ubend *Ubend) Sorted() []float64 {
+	return ubend.sortedInto(nil)
+}
+
+// sortedInto is like [Ubend.Sorted] but reuses the given buffer
+func (ubend *Ubend) sortedInto(buf []float64) []float64 {
+	out := append(buf[:0], ubend.Data[:ubend.Size()]...)
+	slices.Sort(out)
+	return out
+}
+
+// Clone returns a deep copy of the container
+func (ubend *Ubend) Clone() *Ubend {
+	out := *ubend
+	out.Data = make([]float64, len(ubend.Data))
+	copy(out.Data, ubend.Data)
+	return &out
+}
@@ -69,3 +69,45 @@ func TestLastErasedData(t *testing.T) {
 		}
 	}
 }
+
+func TestChronological(t *testing.T) {
+	var size uint64 = 5
+	u := NewUbend(size)
+	if len(u.Chronological()) != 0 {
+		t.Errorf("container must be empty")
+	}
+
+	for i := uint64(0); i < size+2; i++ {
+		u.Push(float64(i))
+		values := u.Chronological()
+		if uint64(len(values)) != u.Size() {
+			t.Fatalf("bad size: %d instead of %d", len(values), u.Size())
+		}
+		for j, x := range values {
+			if x != float64(i+1-u.Size()+uint64(j)) {
+				t.Errorf("bad order: %v", values)
+			}
+		}
+	}
+}
+
+func TestSorted(t *testing.T) {
+	u := NewUbend(4)
+	for _, x := range []float64{3, 1, 4, 1, 5} {
+		u.Push(x)
+	}
+	sorted := u.Sorted()
+	want := []float64{1, 1, 4, 5}
+	for i := range want {
+		if sorted[i] != want[i] {
+			t.Fatalf("bad sorted values: %v", sorted)
+		}
+	}
+	if chrono := u.Chronological(); chrono[0] != 1 || chrono[1] != 4 || chrono[3] != 5 {
+		t.Errorf("the ring must not be modified: %v", chrono)
+	}
+
+	if len(NewUbend(3).Sorted()) != 0 {
+		t.Errorf("empty container must give no value")
+	}
+}